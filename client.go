@@ -26,6 +26,11 @@ type Client interface {
 }
 
 // New instantiates a new HTTP client with the provided context.
+// When the context carries a request ID, such as the context of a request
+// handled behind the server.RequestID middleware, it is sent in the
+// X-Request-ID header of every request. Likewise, the span context stored
+// by the server.Tracing middleware is sent in the traceparent and
+// tracestate headers, so the called services continue the trace.
 func New(ctx context.Context) Client {
 	return client.New(ctx)
 }
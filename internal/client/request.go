@@ -5,6 +5,8 @@ import (
 	"io"
 	"net/http"
 
+	"github.com/i9si-sistemas/nine/internal/requestid"
+	"github.com/i9si-sistemas/nine/internal/tracecontext"
 	public "github.com/i9si-sistemas/nine/pkg/client"
 )
 
@@ -37,6 +39,12 @@ func (c *client) executeRequest(method, url string, options *public.Options) (*h
 		return nil, public.NewRequestError(err)
 	}
 	public.SetHeaders(req, headers)
+	if id := requestid.FromContext(c.ctx); id != "" && req.Header.Get(requestid.Header) == "" {
+		req.Header.Set(requestid.Header, id)
+	}
+	if sc, ok := tracecontext.FromContext(c.ctx); ok && req.Header.Get(tracecontext.TraceparentHeader) == "" {
+		tracecontext.Inject(req.Header, sc)
+	}
 	res, err := c.Response(req)
 	if err != nil {
 		return nil, public.NewRequestError(err)
@@ -10,6 +10,8 @@ import (
 	"testing"
 
 	"github.com/i9si-sistemas/assert"
+	"github.com/i9si-sistemas/nine/internal/requestid"
+	"github.com/i9si-sistemas/nine/internal/tracecontext"
 	public "github.com/i9si-sistemas/nine/pkg/client"
 )
 
@@ -183,3 +185,46 @@ func deleteHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
 	}
 }
+
+func TestRequestIDForwarding(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get(requestid.Header))
+	}))
+	defer server.Close()
+
+	ctx := requestid.NewContext(context.Background(), "req-42")
+	_, err := New(ctx).Get(server.URL, &public.Options{})
+	assert.NoError(t, err)
+	_, err = New(ctx).Get(server.URL, &public.Options{
+		Headers: []public.Header{{Data: public.Data{Key: requestid.Header, Value: "explicit"}}},
+	})
+	assert.NoError(t, err)
+	_, err = New(context.Background()).Get(server.URL, &public.Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, received, []string{"req-42", "explicit", ""})
+}
+
+func TestTraceContextPropagation(t *testing.T) {
+	var traceparent, tracestate string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get(tracecontext.TraceparentHeader)
+		tracestate = r.Header.Get(tracecontext.TracestateHeader)
+	}))
+	defer server.Close()
+
+	sc := tracecontext.SpanContext{
+		TraceID:    tracecontext.NewTraceID(),
+		SpanID:     tracecontext.NewSpanID(),
+		TraceFlags: tracecontext.FlagSampled,
+		TraceState: "vendor=1",
+	}
+	_, err := New(tracecontext.NewContext(context.Background(), sc)).Get(server.URL, &public.Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, traceparent, sc.Traceparent())
+	assert.Equal(t, tracestate, "vendor=1")
+
+	_, err = New(context.Background()).Get(server.URL, &public.Options{})
+	assert.NoError(t, err)
+	assert.Empty(t, traceparent)
+}
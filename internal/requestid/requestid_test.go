@@ -0,0 +1,42 @@
+package requestid
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/i9si-sistemas/assert"
+)
+
+func TestContext(t *testing.T) {
+	assert.Empty(t, FromContext(context.Background()))
+	assert.Empty(t, FromContext(nil))
+	ctx := NewContext(context.Background(), "abc")
+	assert.Equal(t, FromContext(ctx), "abc")
+}
+
+func TestUUIDv7(t *testing.T) {
+	pattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	a, b := UUIDv7(), UUIDv7()
+	assert.True(t, pattern.MatchString(a))
+	assert.NotEqual(t, a, b)
+	assert.True(t, a[:8] <= b[:8])
+}
+
+func TestULID(t *testing.T) {
+	pattern := regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+	a, b := ULID(), ULID()
+	assert.True(t, pattern.MatchString(a))
+	assert.NotEqual(t, a, b)
+	assert.True(t, a[:10] <= b[:10])
+}
+
+func TestValid(t *testing.T) {
+	assert.True(t, Valid(UUIDv7()))
+	assert.True(t, Valid(ULID()))
+	assert.True(t, Valid("trace:a1/b2+c=="))
+	assert.False(t, Valid(""))
+	assert.False(t, Valid("bad id"))
+	assert.False(t, Valid("line\nbreak"))
+	assert.False(t, Valid(string(make([]byte, 129))))
+}
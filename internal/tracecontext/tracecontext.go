@@ -0,0 +1,156 @@
+// Package tracecontext implements the W3C Trace Context propagation format
+// and carries span contexts in contexts, so the server middleware and the
+// HTTP client share them without importing each other.
+package tracecontext
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// Headers of the W3C Trace Context format.
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// FlagSampled is the trace flag telling a trace is recorded.
+const FlagSampled byte = 0x01
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// IsValid reports whether the ID is not all zeros.
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// IsValid reports whether the ID is not all zeros.
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// NewTraceID returns a random trace ID.
+func NewTraceID() (id TraceID) {
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+// NewSpanID returns a random span ID.
+func NewSpanID() (id SpanID) {
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+// SpanContext is the part of a span propagated across services.
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	TraceFlags byte
+	// TraceState is the vendor specific tracestate header, passed as is.
+	TraceState string
+}
+
+// IsValid reports whether the trace and span IDs are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// IsSampled reports whether the trace is recorded.
+func (sc SpanContext) IsSampled() bool {
+	return sc.TraceFlags&FlagSampled != 0
+}
+
+// Traceparent formats the span context as a version 00 traceparent header.
+func (sc SpanContext) Traceparent() string {
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + hex.EncodeToString([]byte{sc.TraceFlags})
+}
+
+// Parse parses the traceparent and tracestate headers. Versions above 00
+// are parsed as 00, as the specification requires, ignoring the fields
+// they add.
+func Parse(traceparent, tracestate string) (sc SpanContext, ok bool) {
+	traceparent = strings.TrimSpace(traceparent)
+	if len(traceparent) < 55 || len(traceparent) > 55 && traceparent[55] != '-' {
+		return SpanContext{}, false
+	}
+	version, ok := decodeHex(traceparent[0:2])
+	if !ok || version[0] == 0xff || version[0] == 0 && len(traceparent) != 55 {
+		return SpanContext{}, false
+	}
+	if traceparent[2] != '-' || traceparent[35] != '-' || traceparent[52] != '-' {
+		return SpanContext{}, false
+	}
+	traceID, ok1 := decodeHex(traceparent[3:35])
+	spanID, ok2 := decodeHex(traceparent[36:52])
+	flags, ok3 := decodeHex(traceparent[53:55])
+	if !ok1 || !ok2 || !ok3 {
+		return SpanContext{}, false
+	}
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.TraceFlags = flags[0]
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	sc.TraceState = strings.TrimSpace(tracestate)
+	return sc, true
+}
+
+// decodeHex decodes lowercase hexadecimal strings only, as the format requires.
+func decodeHex(s string) ([]byte, bool) {
+	if strings.ToLower(s) != s {
+		return nil, false
+	}
+	b, err := hex.DecodeString(s)
+	return b, err == nil
+}
+
+// Extract returns the span context propagated in the request headers.
+func Extract(h http.Header) (SpanContext, bool) {
+	return Parse(h.Get(TraceparentHeader), strings.Join(h.Values(TracestateHeader), ","))
+}
+
+// Inject sets the traceparent and tracestate headers of sc.
+func Inject(h http.Header, sc SpanContext) {
+	h.Set(TraceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		h.Set(TracestateHeader, sc.TraceState)
+	} else {
+		h.Del(TracestateHeader)
+	}
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying sc.
+func NewContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, contextKey{}, sc)
+}
+
+// FromContext returns the span context carried by ctx, if any.
+func FromContext(ctx context.Context) (SpanContext, bool) {
+	if ctx == nil {
+		return SpanContext{}, false
+	}
+	sc, ok := ctx.Value(contextKey{}).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
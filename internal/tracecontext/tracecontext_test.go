@@ -0,0 +1,63 @@
+package tracecontext
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/i9si-sistemas/assert"
+)
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParse(t *testing.T) {
+	sc, ok := Parse(traceparent, " rojo=00f067aa0ba902b7 ")
+	assert.True(t, ok)
+	assert.Equal(t, sc.TraceID.String(), "4bf92f3577b34da6a3ce929d0e0e4736")
+	assert.Equal(t, sc.SpanID.String(), "00f067aa0ba902b7")
+	assert.True(t, sc.IsSampled())
+	assert.Equal(t, sc.TraceState, "rojo=00f067aa0ba902b7")
+	assert.Equal(t, sc.Traceparent(), traceparent)
+
+	sc, ok = Parse("cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-future", "")
+	assert.True(t, ok)
+	assert.False(t, sc.IsSampled())
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00_4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0g",
+		"cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.",
+	} {
+		_, ok := Parse(invalid, "")
+		assert.False(t, ok, invalid)
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	h := http.Header{}
+	h.Add(TracestateHeader, "a=1")
+	h.Add(TracestateHeader, "b=2")
+	h.Set(TraceparentHeader, traceparent)
+	sc, ok := Extract(h)
+	assert.True(t, ok)
+	assert.Equal(t, sc.TraceState, "a=1,b=2")
+
+	sc.SpanID = NewSpanID()
+	out := http.Header{}
+	Inject(out, sc)
+	assert.Equal(t, out.Get(TraceparentHeader), sc.Traceparent())
+	assert.Equal(t, out.Get(TracestateHeader), "a=1,b=2")
+
+	_, ok = FromContext(context.Background())
+	assert.False(t, ok)
+	got, ok := FromContext(NewContext(context.Background(), sc))
+	assert.True(t, ok)
+	assert.Equal(t, got, sc)
+	assert.True(t, NewTraceID() != NewTraceID())
+}
@@ -0,0 +1,221 @@
+package xml
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"unicode"
+
+	"github.com/i9si-sistemas/stringx"
+)
+
+const (
+	// DefaultRoot is the element name used to wrap map and slice values.
+	DefaultRoot = "root"
+	// Header is the XML declaration written before the document.
+	Header = xml.Header
+	// TextKey is the map key whose value becomes the character data of an element.
+	TextKey = "#text"
+	// AttrPrefix marks map keys that are written as attributes of an element.
+	AttrPrefix = "@"
+	// itemName is the element name used for the entries of a top-level slice.
+	itemName = "item"
+)
+
+var (
+	// ErrInvalidName is returned for map keys that are not valid XML names.
+	ErrInvalidName = errors.New("invalid xml name")
+	// ErrInvalidAttrValue is returned for attribute and text values that are
+	// not strings, numbers, booleans nor encoding.TextMarshaler.
+	ErrInvalidAttrValue = errors.New("invalid xml attribute value")
+)
+
+// Marshal returns the XML encoding of v.
+//
+// Maps with string keys (such as nine.JSON) are converted using the same
+// conventions produced by Decode, so both directions round trip:
+//
+//   - the map itself becomes the root element, named after root;
+//   - the "#text" key becomes the character data of its element;
+//   - keys prefixed with "@" become attributes of their element;
+//   - nested maps become child elements;
+//   - slices become repeated elements with the same name;
+//   - any other value is encoded by encoding/xml under the key's name.
+//
+// A top-level slice is wrapped in the root element with one "item" child
+// per entry. Every other value is encoded with encoding/xml, so structs
+// keep their xml tags. Keys are written in lexical order, and keys that are
+// not valid XML names return ErrInvalidName.
+func Marshal(v any, root, indent string) ([]byte, error) {
+	if root == "" {
+		root = DefaultRoot
+	}
+	value := normalize(v)
+	switch value.(type) {
+	case map[string]any, []any:
+	default:
+		return xml.MarshalIndent(v, "", indent)
+	}
+
+	buf := new(bytes.Buffer)
+	encoder := xml.NewEncoder(buf)
+	encoder.Indent("", indent)
+	var err error
+	if items, ok := value.([]any); ok {
+		err = encodeElement(encoder, root, map[string]any{itemName: items})
+	} else {
+		err = encodeElement(encoder, root, value)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := encoder.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeElement(e *xml.Encoder, name string, v any) error {
+	if !validName(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	switch value := normalize(v).(type) {
+	case []any:
+		for _, item := range value {
+			if err := encodeElement(e, name, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]any:
+		var (
+			children []string
+			text     any
+		)
+		for _, key := range sortedKeys(value) {
+			switch {
+			case key == TextKey:
+				text = value[key]
+			case stringx.String(key).HasPrefix(AttrPrefix):
+				attr := stringx.String(key).TrimPrefix(AttrPrefix).String()
+				if !validName(attr) {
+					return fmt.Errorf("%w: %q", ErrInvalidName, attr)
+				}
+				attrValue, err := scalar(value[key])
+				if err != nil {
+					return fmt.Errorf("%w: %q", err, attr)
+				}
+				start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: attr}, Value: attrValue})
+			default:
+				children = append(children, key)
+			}
+		}
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		if text != nil {
+			data, err := scalar(text)
+			if err != nil {
+				return fmt.Errorf("%w: %q", err, TextKey)
+			}
+			if err := e.EncodeToken(xml.CharData(data)); err != nil {
+				return err
+			}
+		}
+		for _, key := range children {
+			if err := encodeElement(e, key, value[key]); err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	case nil:
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		return e.EncodeToken(start.End())
+	default:
+		return e.EncodeElement(value, start)
+	}
+}
+
+// validName reports whether name matches the Name production of the XML
+// specification, such as "user" or "xs:id", but not "1x" nor "a b".
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case unicode.IsLetter(r), r == '_', r == ':':
+		case i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.' || r == '\u00B7' || unicode.In(r, unicode.Mn, unicode.Mc)):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// scalar formats the strings, numbers, booleans and encoding.TextMarshaler
+// written as attributes or character data, which have no element to nest in.
+func scalar(v any) (string, error) {
+	if marshaler, ok := v.(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		return string(text), err
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v), nil
+	}
+	return "", ErrInvalidAttrValue
+}
+
+// normalize converts named map and slice types into map[string]any and []any
+// so that every JSON-like type shares the same encoding path.
+func normalize(v any) any {
+	switch value := v.(type) {
+	case nil:
+		return nil
+	case map[string]any, []any:
+		return value
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return v
+		}
+		m := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = iter.Value().Interface()
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return v
+		}
+		items := make([]any, rv.Len())
+		for i := range rv.Len() {
+			items[i] = rv.Index(i).Interface()
+		}
+		return items
+	}
+	return v
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
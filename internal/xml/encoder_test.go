@@ -0,0 +1,91 @@
+package xml
+
+import (
+	"encoding/xml"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/i9si-sistemas/assert"
+	"github.com/i9si-sistemas/stringx"
+)
+
+func TestMarshal(t *testing.T) {
+	t.Run("Map", func(t *testing.T) {
+		type JSON map[string]any
+		b, err := Marshal(JSON{
+			"@id":  1,
+			"name": "Gabriel",
+			"tags": []string{"go", "http"},
+			"address": JSON{
+				"@kind": "home",
+				"#text": "Main Street",
+			},
+		}, "user", "")
+		assert.NoError(t, err)
+		expected := `<user id="1"><address kind="home">Main Street</address>` +
+			`<name>Gabriel</name><tags>go</tags><tags>http</tags></user>`
+		assert.Equal(t, string(b), expected)
+	})
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		input := map[string]any{
+			"name": map[string]any{"#text": "Gabriel"},
+			"item": []any{
+				map[string]any{"#text": "a"},
+				map[string]any{"#text": "b"},
+			},
+		}
+		b, err := Marshal(input, "", "  ")
+		assert.NoError(t, err)
+		got, err := Decode(stringx.NewReader(string(b)))
+		assert.NoError(t, err)
+		assert.Equal(t, got, input)
+	})
+
+	t.Run("Slice", func(t *testing.T) {
+		b, err := Marshal([]int{1, 2}, "numbers", "")
+		assert.NoError(t, err)
+		assert.Equal(t, string(b), "<numbers><item>1</item><item>2</item></numbers>")
+	})
+
+	t.Run("Struct", func(t *testing.T) {
+		type user struct {
+			XMLName xml.Name `xml:"user"`
+			ID      int      `xml:"id,attr"`
+			Name    string   `xml:"name"`
+		}
+		b, err := Marshal(user{ID: 7, Name: "<gopher>"}, "", "")
+		assert.NoError(t, err)
+		assert.Equal(t, string(b), `<user id="7"><name>&lt;gopher&gt;</name></user>`)
+	})
+
+	t.Run("InvalidNames", func(t *testing.T) {
+		for _, v := range []map[string]any{
+			{"a b": 1},
+			{"1x": 1},
+			{"": 1},
+			{"@a b": 1},
+			{"@": 1},
+		} {
+			_, err := Marshal(v, "", "")
+			assert.True(t, errors.Is(err, ErrInvalidName))
+		}
+		_, err := Marshal(map[string]any{"id": 1}, "bad root", "")
+		assert.True(t, errors.Is(err, ErrInvalidName))
+	})
+
+	t.Run("AttrValues", func(t *testing.T) {
+		for _, value := range []any{nil, map[string]any{"a": 1}, []int{1}, struct{}{}} {
+			_, err := Marshal(map[string]any{"@id": value}, "", "")
+			assert.True(t, errors.Is(err, ErrInvalidAttrValue))
+		}
+		_, err := Marshal(map[string]any{"#text": []int{1}}, "", "")
+		assert.True(t, errors.Is(err, ErrInvalidAttrValue))
+
+		at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		b, err := Marshal(map[string]any{"@at": at, "@ok": true, "@n": 1.5, "xs:id": "x"}, "event", "")
+		assert.NoError(t, err)
+		assert.Equal(t, string(b), `<event at="2024-01-02T03:04:05Z" n="1.5" ok="true"><xs:id>x</xs:id></event>`)
+	})
+}
@@ -0,0 +1,280 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/i9si-sistemas/nine/internal/requestid"
+)
+
+// AccessLogFormat is the output format of the AccessLog middleware.
+type AccessLogFormat int
+
+const (
+	// AccessLogStructured logs every request through AccessLogConfig.Logger,
+	// with one attribute per field.
+	AccessLogStructured AccessLogFormat = iota
+	// AccessLogJSON writes one JSON object per request to AccessLogConfig.Output.
+	AccessLogJSON
+	// AccessLogCommon writes the NCSA Common Log Format to AccessLogConfig.Output.
+	AccessLogCommon
+	// AccessLogCombined writes the NCSA Combined Log Format, which adds the
+	// referer and user agent to the Common one, to AccessLogConfig.Output.
+	AccessLogCombined
+)
+
+// redacted replaces the values of sensitive headers and query parameters.
+const redacted = "REDACTED"
+
+// DefaultRedactedHeaders are the request headers whose values are never logged.
+var DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key"}
+
+// DefaultRedactedQuery are the query parameters whose values are never logged.
+var DefaultRedactedQuery = []string{"token", "access_token", "api_key", "apikey", "password", "secret"}
+
+// AccessLogConfig configures the AccessLog middleware.
+type AccessLogConfig struct {
+	Format AccessLogFormat
+	// Logger receives the AccessLogStructured records. Defaults to slog.Default().
+	Logger *slog.Logger
+	// Output receives the other formats. Defaults to os.Stdout.
+	Output io.Writer
+	// Level is the level of the structured records, server errors
+	// are always logged with slog.LevelError.
+	Level slog.Level
+	// SampleRate is the fraction, between 0 and 1, of the requests logged.
+	// Zero logs every request. Server errors are always logged.
+	SampleRate float64
+	// SkipPaths lists the paths that are not logged, such as health checks.
+	// An entry ending with "*" skips every path starting with it.
+	SkipPaths []string
+	// Skip reports whether a request must not be logged.
+	Skip func(c *Context) bool
+	// Headers lists the request headers added to structured records.
+	Headers []string
+	// RedactHeaders defaults to DefaultRedactedHeaders.
+	RedactHeaders []string
+	// RedactQuery defaults to DefaultRedactedQuery.
+	RedactQuery []string
+}
+
+// AccessLog logs every request once its response is written, with its
+// method, route pattern, path, status, size, latency, client IP and
+// request ID. It only sees the responses of the middlewares after it;
+// Server.AccessLog logs the requests of every route.
+//
+//	api := server.Group("/api", i9.AccessLog(i9.AccessLogConfig{
+//		Format:    i9.AccessLogJSON,
+//		SkipPaths: []string{"/api/healthz"},
+//	}))
+func AccessLog(options ...AccessLogConfig) HandlerWithContext {
+	var config AccessLogConfig
+	if len(options) > 0 {
+		config = options[0]
+	}
+	if config.Output == nil {
+		config.Output = os.Stdout
+	}
+	if config.RedactHeaders == nil {
+		config.RedactHeaders = DefaultRedactedHeaders
+	}
+	if config.RedactQuery == nil {
+		config.RedactQuery = DefaultRedactedQuery
+	}
+	logger := config.Logger
+	if config.Format == AccessLogJSON {
+		logger = slog.New(slog.NewJSONHandler(config.Output, nil))
+	}
+	var mu sync.Mutex
+
+	return func(c *Context) error {
+		if config.skip(c) {
+			return nil
+		}
+		start := time.Now()
+		w := newResponseWriter(c.Response.HTTP())
+		c.Response.ChangeResponseWriter(w)
+		c.Next()
+		latency := time.Since(start)
+
+		status := w.Status()
+		if status < http.StatusInternalServerError && config.SampleRate > 0 && rand.Float64() >= config.SampleRate {
+			return nil
+		}
+		entry := accessLogEntry{
+			config:  &config,
+			r:       c.Request.HTTP(),
+			route:   routePath(c.PathRegistred()),
+			ip:      c.IP(),
+			status:  status,
+			bytes:   w.Bytes(),
+			latency: latency,
+			start:   start,
+			id:      c.RequestID(),
+		}
+		// The RequestID middleware may run after this one.
+		if id := w.Header().Get(RequestIDHeader); entry.id == "" && requestid.Valid(id) {
+			entry.id = id
+		}
+		switch config.Format {
+		case AccessLogCommon, AccessLogCombined:
+			line := entry.clf(config.Format == AccessLogCombined)
+			mu.Lock()
+			defer mu.Unlock()
+			io.WriteString(config.Output, line)
+		default:
+			entry.log(c.Request.Context(), logger)
+		}
+		return nil
+	}
+}
+
+func (config *AccessLogConfig) skip(c *Context) bool {
+	path := c.Request.Path()
+	for _, skip := range config.SkipPaths {
+		if prefix, ok := strings.CutSuffix(skip, "*"); ok && strings.HasPrefix(path, prefix) || skip == path {
+			return true
+		}
+	}
+	return config.Skip != nil && config.Skip(c)
+}
+
+type accessLogEntry struct {
+	config  *AccessLogConfig
+	r       *http.Request
+	route   string
+	ip      string
+	status  int
+	bytes   int64
+	latency time.Duration
+	start   time.Time
+	id      string
+}
+
+func (e accessLogEntry) log(ctx context.Context, logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	attrs := []slog.Attr{
+		slog.String("method", e.r.Method),
+		slog.String("route", e.route),
+		slog.String("path", e.r.URL.Path),
+	}
+	if query := e.query(); query != "" {
+		attrs = append(attrs, slog.String("query", query))
+	}
+	attrs = append(attrs,
+		slog.Int("status", e.status),
+		slog.Int64("bytes", e.bytes),
+		slog.Duration("latency", e.latency),
+		slog.String("ip", e.ip),
+	)
+	if e.id != "" {
+		attrs = append(attrs, slog.String("request_id", e.id))
+	}
+	if len(e.config.Headers) > 0 {
+		var headers []any
+		for _, name := range e.config.Headers {
+			if value := e.r.Header.Get(name); value != "" {
+				headers = append(headers, slog.String(name, e.header(name, value)))
+			}
+		}
+		attrs = append(attrs, slog.Group("headers", headers...))
+	}
+	level := e.config.Level
+	if e.status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	logger.LogAttrs(ctx, level, "request", attrs...)
+}
+
+// clf formats the entry in the Common or Combined Log Format.
+func (e accessLogEntry) clf(combined bool) string {
+	user := "-"
+	if name, _, ok := e.r.BasicAuth(); ok && name != "" {
+		user = name
+	}
+	uri := e.r.URL.Path
+	if query := e.query(); query != "" {
+		uri += "?" + query
+	}
+	size := "-"
+	if e.bytes > 0 {
+		size = fmt.Sprint(e.bytes)
+	}
+	line := fmt.Sprintf("%s - %s [%s] %q %d %s",
+		e.ip, user, e.start.Format("02/Jan/2006:15:04:05 -0700"),
+		e.r.Method+" "+uri+" "+e.r.Proto, e.status, size)
+	if combined {
+		line += fmt.Sprintf(" %q %q", e.redactURL(e.r.Referer()), e.r.UserAgent())
+	}
+	return line + "\n"
+}
+
+func (e accessLogEntry) header(name, value string) string {
+	if slices.ContainsFunc(e.config.RedactHeaders, func(h string) bool {
+		return strings.EqualFold(h, name)
+	}) {
+		return redacted
+	}
+	return value
+}
+
+// query returns the raw query of the request with its sensitive values redacted.
+func (e accessLogEntry) query() string {
+	return redactQuery(e.r.URL.RawQuery, e.config.RedactQuery)
+}
+
+func (e accessLogEntry) redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.RawQuery == "" {
+		return raw
+	}
+	u.RawQuery = redactQuery(u.RawQuery, e.config.RedactQuery)
+	return u.String()
+}
+
+func redactQuery(raw string, names []string) string {
+	if raw == "" {
+		return ""
+	}
+	parts := strings.Split(raw, "&")
+	for i, part := range parts {
+		key, _, ok := strings.Cut(part, "=")
+		if name, err := url.QueryUnescape(key); ok && err == nil &&
+			slices.ContainsFunc(names, func(n string) bool { return strings.EqualFold(n, name) }) {
+			parts[i] = key + "=" + redacted
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// routePath returns the path of a registered pattern such as "GET /users/{id}".
+func routePath(pattern string) string {
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		return path
+	}
+	return pattern
+}
+
+// AccessLog logs the requests of every route, wrapping the middlewares of
+// the routes and groups, so the requests they answer on their own, such as
+// a 401 or a 429, are logged too.
+//
+//	server.AccessLog(i9.AccessLogConfig{
+//		Format:    i9.AccessLogJSON,
+//		SkipPaths: []string{"/healthz", "/static/*"},
+//	})
+func (s *Server) AccessLog(options ...AccessLogConfig) {
+	s.useOuter(AccessLog(options...))
+}
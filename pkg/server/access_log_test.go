@@ -0,0 +1,202 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/i9si-sistemas/assert"
+	"github.com/i9si-sistemas/nine/internal/json"
+)
+
+func newAccessLogServer(config AccessLogConfig) *Server {
+	server := New(0)
+	server.AccessLog(config)
+	server.Get("/users/:id", func(c *Context) error {
+		c.Response.SetHeader("X-Request-ID", "req-1")
+		return c.Status(http.StatusCreated).SendString("created")
+	})
+	server.Get("/fail", func(c *Context) error {
+		return &Error{StatusCode: http.StatusBadGateway, Err: errors.New("upstream")}
+	})
+	server.Get("/healthz", func(c *Context) error {
+		return c.SendString("ok")
+	})
+	return server
+}
+
+func TestAccessLog(t *testing.T) {
+	t.Run("JSON", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		server := newAccessLogServer(AccessLogConfig{
+			Format:  AccessLogJSON,
+			Output:  out,
+			Headers: []string{"Authorization", "Accept"},
+		})
+		req := httptest.NewRequest(http.MethodGet, "/users/42?page=2&token=secret", nil)
+		req.RemoteAddr = "203.0.113.5:4000"
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("Accept", "text/plain")
+		res := server.Test().Request(req)
+		assert.Equal(t, res.Code, http.StatusCreated)
+
+		var record JSON
+		assert.NoError(t, json.Decode(out.Bytes(), &record))
+		assert.Equal(t, record["msg"], "request")
+		assert.Equal(t, record["method"], "GET")
+		assert.Equal(t, record["route"], "/users/{id}")
+		assert.Equal(t, record["path"], "/users/42")
+		assert.Equal(t, record["query"], "page=2&token=REDACTED")
+		assert.Equal(t, record["status"], float64(http.StatusCreated))
+		assert.Equal(t, record["bytes"], float64(len("created")))
+		assert.Equal(t, record["ip"], "203.0.113.5")
+		assert.Equal(t, record["request_id"], "req-1")
+		assert.Equal(t, record["headers"], map[string]any{"Authorization": "REDACTED", "Accept": "text/plain"})
+		assert.NotNil(t, record["latency"])
+	})
+
+	t.Run("RequestID", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		server := newAccessLogServer(AccessLogConfig{Format: AccessLogJSON, Output: out})
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		req.Header.Set(RequestIDHeader, "forged\" injected")
+		server.Test().Request(req)
+		var record JSON
+		assert.NoError(t, json.Decode(out.Bytes(), &record))
+		assert.Nil(t, record["request_id"])
+
+		out.Reset()
+		server.Use(RequestID())
+		server.Test().Request(req)
+		assert.NoError(t, json.Decode(out.Bytes(), &record))
+		assert.NotEqual(t, record["request_id"], "forged\" injected")
+		assert.NotEmpty(t, record["request_id"])
+	})
+
+	t.Run("Structured", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		server := newAccessLogServer(AccessLogConfig{
+			Logger: slog.New(slog.NewTextHandler(out, nil)),
+		})
+		server.Test().Request(httptest.NewRequest(http.MethodGet, "/fail", nil))
+		line := out.String()
+		assert.True(t, strings.Contains(line, "level=ERROR"))
+		assert.True(t, strings.Contains(line, "status=502"))
+		assert.True(t, strings.Contains(line, "route=/fail"))
+	})
+
+	t.Run("Combined", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		server := newAccessLogServer(AccessLogConfig{Format: AccessLogCombined, Output: out})
+		req := httptest.NewRequest(http.MethodGet, "/users/7?password=hunter2", nil)
+		req.RemoteAddr = "198.51.100.1:1234"
+		req.SetBasicAuth("alice", "pw")
+		req.Header.Set("Referer", "https://example.com/login?token=abc")
+		req.Header.Set("User-Agent", "curl/8.0")
+		server.Test().Request(req)
+
+		line := out.String()
+		assert.True(t, strings.HasPrefix(line, "198.51.100.1 - alice ["))
+		assert.True(t, strings.HasSuffix(line,
+			`] "GET /users/7?password=REDACTED HTTP/1.1" 201 7 "https://example.com/login?token=REDACTED" "curl/8.0"`+"\n"))
+	})
+
+	t.Run("Common", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		server := newAccessLogServer(AccessLogConfig{Format: AccessLogCommon, Output: out})
+		server.Test().Request(httptest.NewRequest(http.MethodGet, "/fail", nil))
+		assert.True(t, strings.HasSuffix(out.String(), `] "GET /fail HTTP/1.1" 502 9`+"\n"))
+	})
+
+	t.Run("Skip", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		server := newAccessLogServer(AccessLogConfig{
+			Format:    AccessLogCommon,
+			Output:    out,
+			SkipPaths: []string{"/healthz", "/users/*"},
+		})
+		server.Test().Request(httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		server.Test().Request(httptest.NewRequest(http.MethodGet, "/users/1", nil))
+		assert.Empty(t, out.String())
+	})
+
+	t.Run("Sampling", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		server := newAccessLogServer(AccessLogConfig{
+			Format:     AccessLogCommon,
+			Output:     out,
+			SampleRate: 1e-9,
+		})
+		for range 10 {
+			server.Test().Request(httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		}
+		assert.Empty(t, out.String())
+		server.Test().Request(httptest.NewRequest(http.MethodGet, "/fail", nil))
+		assert.Equal(t, strings.Count(out.String(), "\n"), 1)
+	})
+
+	t.Run("GroupMiddlewares", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		server := newAccessLogServer(AccessLogConfig{Format: AccessLogCommon, Output: out})
+		admin := server.Group("/admin", func(c *Context) error {
+			return &Error{StatusCode: http.StatusUnauthorized, Err: errors.New("unauthorized")}
+		})
+		admin.Get("/users", func(c *Context) error {
+			return c.SendString("users")
+		})
+		res := server.Test().Request(httptest.NewRequest(http.MethodGet, "/admin/users", nil))
+		assert.Equal(t, res.Code, http.StatusUnauthorized)
+		assert.True(t, strings.Contains(out.String(), `"GET /admin/users HTTP/1.1" 401`))
+	})
+}
+
+func TestAccessLogStreaming(t *testing.T) {
+	out := new(safeBuffer)
+	server := New(0)
+	server.AccessLog(AccessLogConfig{Format: AccessLogCommon, Output: out})
+	server.WebSocket("/ws", func(conn *WebSocketConn) error {
+		return conn.WriteMessage(TextMessage, []byte("hi"))
+	})
+	conn, _, err := server.Test().WebSocket("/ws")
+	assert.NoError(t, err)
+	_, data, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), "hi")
+	conn.ReadMessage()
+	conn.Close()
+	deadline := time.Now().Add(time.Second)
+	for out.String() == "" && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.True(t, strings.Contains(out.String(), `"GET /ws HTTP/1.1" 101`))
+}
+
+type safeBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *safeBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *safeBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestBanner(t *testing.T) {
+	assert.True(t, New(0).banner != nil)
+	assert.True(t, New(0, ServerOpts{DisableBanner: true}).banner == nil)
+	custom := New(0, ServerOpts{Banner: func(address string) string { return "listening on " + address }})
+	assert.Equal(t, custom.banner("http://127.0.0.1:80"), "listening on http://127.0.0.1:80")
+}
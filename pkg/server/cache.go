@@ -0,0 +1,507 @@
+package server
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of the response cache.
+const (
+	DefaultCacheTTL         = time.Minute
+	DefaultCacheMaxEntries  = 1000
+	DefaultCacheMaxBodySize = 1 << 20
+)
+
+// cacheableStatus lists the statuses cached by default, per RFC 9110.
+var cacheableStatus = []int{
+	http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent,
+	http.StatusMultipleChoices, http.StatusMovedPermanently, http.StatusNotFound,
+	http.StatusMethodNotAllowed, http.StatusGone, http.StatusRequestURITooLong,
+	http.StatusNotImplemented,
+}
+
+// ResponseCache is a bounded LRU of responses, shared by the Cache
+// middlewares given it, which handlers use to invalidate entries.
+type ResponseCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	lru        *list.List
+	entries    map[string]*list.Element
+	vary       map[string][]string
+	byKey      map[string]map[string]struct{}
+	byTag      map[string]map[string]struct{}
+}
+
+type cacheEntry struct {
+	id, key      string
+	status       int
+	header       http.Header
+	body         []byte
+	tags         []string
+	stored       time.Time
+	expires      time.Time
+	staleUntil   time.Time
+	revalidating bool
+}
+
+// NewResponseCache returns an empty cache holding up to maxEntries
+// responses, DefaultCacheMaxEntries when not positive.
+func NewResponseCache(maxEntries int) *ResponseCache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultCacheMaxEntries
+	}
+	return &ResponseCache{
+		maxEntries: maxEntries,
+		lru:        list.New(),
+		entries:    map[string]*list.Element{},
+		vary:       map[string][]string{},
+		byKey:      map[string]map[string]struct{}{},
+		byTag:      map[string]map[string]struct{}{},
+	}
+}
+
+// Len returns the number of cached responses.
+func (rc *ResponseCache) Len() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.lru.Len()
+}
+
+// Invalidate removes the responses cached under the given keys, every
+// variant included. Keys are built by CacheKey, as
+// "GET example.com/articles?page=2".
+func (rc *ResponseCache) Invalidate(keys ...string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for _, key := range keys {
+		for id := range rc.byKey[key] {
+			rc.remove(id)
+		}
+	}
+}
+
+// InvalidateTags removes the responses tagged with any of the given tags,
+// see Context.CacheTags.
+func (rc *ResponseCache) InvalidateTags(tags ...string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for _, tag := range tags {
+		for id := range rc.byTag[tag] {
+			rc.remove(id)
+		}
+	}
+}
+
+// Purge removes every response.
+func (rc *ResponseCache) Purge() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for id := range rc.entries {
+		rc.remove(id)
+	}
+}
+
+func (rc *ResponseCache) remove(id string) {
+	element, ok := rc.entries[id]
+	if !ok {
+		return
+	}
+	entry := element.Value.(*cacheEntry)
+	rc.lru.Remove(element)
+	delete(rc.entries, id)
+	if ids := rc.byKey[entry.key]; ids != nil {
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(rc.byKey, entry.key)
+			delete(rc.vary, entry.key)
+		}
+	}
+	for _, tag := range entry.tags {
+		if ids := rc.byTag[tag]; ids != nil {
+			delete(ids, id)
+			if len(ids) == 0 {
+				delete(rc.byTag, tag)
+			}
+		}
+	}
+}
+
+// lookup returns the entry of the request variant, and whether the caller
+// must revalidate it, which happens once per stale entry.
+func (rc *ResponseCache) lookup(key string, r *http.Request, varyHeaders []string, now time.Time) (entry cacheEntry, found, revalidate bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	vary, ok := rc.vary[key]
+	if !ok {
+		vary = varyHeaders
+	}
+	element, ok := rc.entries[variantID(key, r, vary)]
+	if !ok {
+		return entry, false, false
+	}
+	e := element.Value.(*cacheEntry)
+	if now.After(e.staleUntil) {
+		rc.remove(e.id)
+		return entry, false, false
+	}
+	rc.lru.MoveToFront(element)
+	if now.After(e.expires) && !e.revalidating {
+		e.revalidating, revalidate = true, true
+	}
+	return *e, true, revalidate
+}
+
+func (rc *ResponseCache) store(entry *cacheEntry, vary []string, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.vary[entry.key] = vary
+	entry.id = variantID(entry.key, r, vary)
+	rc.remove(entry.id)
+	rc.entries[entry.id] = rc.lru.PushFront(entry)
+	if rc.byKey[entry.key] == nil {
+		rc.byKey[entry.key] = map[string]struct{}{}
+	}
+	rc.byKey[entry.key][entry.id] = struct{}{}
+	for _, tag := range entry.tags {
+		if rc.byTag[tag] == nil {
+			rc.byTag[tag] = map[string]struct{}{}
+		}
+		rc.byTag[tag][entry.id] = struct{}{}
+	}
+	for rc.lru.Len() > rc.maxEntries {
+		rc.remove(rc.lru.Back().Value.(*cacheEntry).id)
+	}
+}
+
+// doneRevalidating allows a new revalidation of a stale entry, after one failed.
+func (rc *ResponseCache) doneRevalidating(key string, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if element, ok := rc.entries[variantID(key, r, rc.vary[key])]; ok {
+		element.Value.(*cacheEntry).revalidating = false
+	}
+}
+
+// variantID identifies the variant of a response by the values of the
+// request headers it varies on.
+func variantID(key string, r *http.Request, vary []string) string {
+	var b strings.Builder
+	b.WriteString(key)
+	for _, name := range vary {
+		b.WriteString("\n" + name + ":" + strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+// CacheKey returns the key of a request in the response cache: its method,
+// HEAD being cached as GET, host, path and query sorted by parameter.
+func CacheKey(r *http.Request) string {
+	method := r.Method
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	key := method + " " + strings.ToLower(r.Host) + r.URL.EscapedPath()
+	if query := r.URL.Query().Encode(); query != "" {
+		key += "?" + query
+	}
+	return key
+}
+
+// CacheConfig configures the Cache middleware.
+type CacheConfig struct {
+	// Cache stores the responses. Share one between routes to invalidate
+	// their responses from other handlers. Defaults to a new ResponseCache.
+	Cache *ResponseCache
+	// TTL is how long a response is fresh, unless its Cache-Control
+	// header sets s-maxage or max-age. Defaults to DefaultCacheTTL.
+	TTL time.Duration
+	// StaleWhileRevalidate is how long a response is still served once
+	// expired, while it is refreshed in the background, unless its
+	// Cache-Control header sets stale-while-revalidate.
+	StaleWhileRevalidate time.Duration
+	// VaryHeaders lists request headers, such as Accept-Language, whose
+	// values get distinct responses, on top of the response Vary header.
+	VaryHeaders []string
+	// MaxBodySize is the size of the largest response cached.
+	// Defaults to DefaultCacheMaxBodySize.
+	MaxBodySize int
+	// Key defaults to CacheKey.
+	Key func(r *http.Request) string
+}
+
+// Cache serves the responses of GET and HEAD requests from a cache,
+// setting an Age header and an X-Cache header to HIT, STALE or MISS.
+// Responses are not cached when their Cache-Control header holds no-store,
+// no-cache or private, when they set cookies or vary on every header, nor
+// for requests with an Authorization or a Cookie header, whose responses
+// may be personal. Added to a route, the TTL only applies to it.
+//
+//	cache := i9.NewResponseCache(500)
+//	server.Get("/articles", i9.Cache(i9.CacheConfig{Cache: cache, TTL: time.Hour}), func(c *i9.Context) error {
+//		c.CacheTags("articles")
+//		return c.JSON(articles.List())
+//	})
+//	server.Post("/articles", func(c *i9.Context) error {
+//		// create the article
+//		cache.InvalidateTags("articles")
+//		return c.SendStatus(http.StatusCreated)
+//	})
+func Cache(options ...CacheConfig) HandlerWithContext {
+	var config CacheConfig
+	if len(options) > 0 {
+		config = options[0]
+	}
+	if config.Cache == nil {
+		config.Cache = NewResponseCache(0)
+	}
+	if config.TTL <= 0 {
+		config.TTL = DefaultCacheTTL
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = DefaultCacheMaxBodySize
+	}
+	if config.Key == nil {
+		config.Key = CacheKey
+	}
+	vary := make([]string, len(config.VaryHeaders))
+	for i, name := range config.VaryHeaders {
+		vary[i] = http.CanonicalHeaderKey(name)
+	}
+	slices.Sort(vary)
+	config.VaryHeaders = vary
+	cache := config.Cache
+
+	return func(c *Context) error {
+		r := c.Request.HTTP()
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			return nil
+		}
+		if r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "" {
+			return nil
+		}
+		key := config.Key(r)
+		now := time.Now()
+		entry, found, revalidate := cache.lookup(key, r, config.VaryHeaders, now)
+		if found {
+			state := "HIT"
+			if now.After(entry.expires) {
+				state = "STALE"
+			}
+			err := c.Response.write(func() error {
+				return entry.writeTo(c.Response.HTTP(), r, state, now)
+			})
+			if revalidate && (err != nil || c.Response.nextHandler == nil) {
+				cache.doneRevalidating(key, r)
+			} else if revalidate {
+				go config.refresh(c.Response.nextHandler, r.Clone(context.WithoutCancel(r.Context())), key)
+			}
+			return err
+		}
+
+		rec, req := config.record(c.Response.HTTP(), r)
+		rec.ResponseWriter.Header().Set("X-Cache", "MISS")
+		c.Request.SetContext(req.Context())
+		c.Response.ChangeResponseWriter(rec)
+		c.Next()
+		config.save(rec, key, r)
+		return nil
+	}
+}
+
+// record returns a writer recording the response written to w, and r
+// holding the tags of the response.
+func (config *CacheConfig) record(w http.ResponseWriter, r *http.Request) (*cacheRecorder, *http.Request) {
+	var tags []string
+	r = r.WithContext(context.WithValue(r.Context(), cacheTagsKey{}, &tags))
+	rec := &cacheRecorder{wrappedWriter: wrappedWriter{ResponseWriter: w}, limit: config.MaxBodySize, tags: &tags}
+	rec.beforeFlush, rec.beforeHijack = rec.skip, rec.skip
+	return rec, r
+}
+
+// refresh revalidates a stale entry once its response was sent, running
+// the rest of the chain with a request and a writer of its own.
+func (config *CacheConfig) refresh(next http.Handler, r *http.Request, key string) {
+	rec, r := config.record(&discardWriter{header: http.Header{}}, r)
+	next.ServeHTTP(rec, r)
+	if !config.save(rec, key, r) {
+		config.Cache.doneRevalidating(key, r)
+	}
+}
+
+// save stores the recorded response when it is cacheable. Responses to HEAD
+// requests have no body to store.
+func (config *CacheConfig) save(rec *cacheRecorder, key string, r *http.Request) bool {
+	if r.Method == http.MethodHead || rec.uncacheable || !slices.Contains(cacheableStatus, rec.statusCode()) {
+		return false
+	}
+	header := rec.header
+	if header == nil {
+		header = rec.ResponseWriter.Header().Clone()
+	}
+	if header.Get("Set-Cookie") != "" {
+		return false
+	}
+	if header.Get("Content-Type") == "" && rec.body.Len() > 0 {
+		header.Set("Content-Type", http.DetectContentType(rec.body.Bytes()))
+	}
+	directives := parseCacheControl(header.Get("Cache-Control"))
+	for _, name := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[name]; ok {
+			return false
+		}
+	}
+	ttl := config.TTL
+	if seconds, ok := directiveSeconds(directives, "s-maxage", "max-age"); ok {
+		ttl = seconds
+	}
+	swr := config.StaleWhileRevalidate
+	if seconds, ok := directiveSeconds(directives, "stale-while-revalidate"); ok {
+		swr = seconds
+	}
+	if ttl <= 0 && swr <= 0 {
+		return false
+	}
+
+	vary := slices.Clone(config.VaryHeaders)
+	for _, line := range header.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "*" {
+				return false
+			}
+			if name != "" && !slices.Contains(vary, name) {
+				vary = append(vary, name)
+			}
+		}
+	}
+	slices.Sort(vary)
+
+	header.Del("X-Cache")
+	now := time.Now()
+	config.Cache.store(&cacheEntry{
+		key:        key,
+		status:     rec.statusCode(),
+		header:     header,
+		body:       rec.body.Bytes(),
+		tags:       *rec.tags,
+		stored:     now,
+		expires:    now.Add(ttl),
+		staleUntil: now.Add(ttl + swr),
+	}, vary, r)
+	return true
+}
+
+func (e *cacheEntry) writeTo(w http.ResponseWriter, r *http.Request, state string, now time.Time) error {
+	h := w.Header()
+	for name, values := range e.header {
+		h[name] = slices.Clone(values)
+	}
+	h.Set("Age", strconv.Itoa(int(now.Sub(e.stored).Seconds())))
+	h.Set("X-Cache", state)
+	h.Set("Content-Length", strconv.Itoa(len(e.body)))
+	w.WriteHeader(e.status)
+	if r.Method == http.MethodHead {
+		return nil
+	}
+	_, err := w.Write(e.body)
+	return err
+}
+
+func parseCacheControl(header string) map[string]string {
+	directives := map[string]string{}
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+	}
+	return directives
+}
+
+// directiveSeconds returns the first of the given directives holding a
+// number of seconds.
+func directiveSeconds(directives map[string]string, names ...string) (time.Duration, bool) {
+	for _, name := range names {
+		if value, ok := directives[name]; ok {
+			if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+				return time.Duration(seconds) * time.Second, true
+			}
+		}
+	}
+	return 0, false
+}
+
+type cacheTagsKey struct{}
+
+// CacheTags tags the response being cached by the Cache middleware, so
+// ResponseCache.InvalidateTags removes it.
+//
+//	c.CacheTags("articles", "article:"+id)
+func (c *Context) CacheTags(tags ...string) {
+	if holder, ok := c.Request.Context().Value(cacheTagsKey{}).(*[]string); ok {
+		*holder = append(*holder, tags...)
+	}
+}
+
+// cacheRecorder passes the response through while recording it, up to a
+// size limit. Streamed and hijacked responses are not cached.
+type cacheRecorder struct {
+	wrappedWriter
+	status      int
+	header      http.Header
+	body        bytes.Buffer
+	limit       int
+	tags        *[]string
+	uncacheable bool
+}
+
+func (w *cacheRecorder) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *cacheRecorder) WriteHeader(code int) {
+	if w.status == 0 && code >= http.StatusOK {
+		w.status = code
+		w.header = w.ResponseWriter.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *cacheRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.uncacheable {
+		if w.body.Len()+len(b) > w.limit {
+			w.uncacheable = true
+			w.body = bytes.Buffer{}
+		} else {
+			w.body.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// skip keeps streamed and hijacked responses out of the cache.
+func (w *cacheRecorder) skip() {
+	w.uncacheable = true
+}
+
+// discardWriter receives the responses of background revalidations.
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(int)             {}
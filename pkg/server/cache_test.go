@@ -0,0 +1,174 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/i9si-sistemas/assert"
+)
+
+func TestCacheKey(t *testing.T) {
+	for target, expected := range map[string]string{
+		"/articles":                    "GET example.com/articles",
+		"/articles?b=2&a=1":            "GET example.com/articles?a=1&b=2",
+		"/articles?a=2&a=1":            "GET example.com/articles?a=2&a=1",
+		"/search?q=hello%20world":      "GET example.com/search?q=hello+world",
+		"http://API.example.com/items": "GET api.example.com/items",
+	} {
+		assert.Equal(t, CacheKey(httptest.NewRequest(http.MethodGet, target, nil)), expected, target)
+	}
+	assert.Equal(t, CacheKey(httptest.NewRequest(http.MethodHead, "/articles", nil)), "GET example.com/articles")
+}
+
+func TestCache(t *testing.T) {
+	cache := NewResponseCache(0)
+	var calls atomic.Int32
+	server := New(0)
+	server.Get("/articles", Cache(CacheConfig{Cache: cache, VaryHeaders: []string{"accept-language"}}), func(c *Context) error {
+		n := calls.Add(1)
+		c.CacheTags("articles")
+		c.Response.SetHeader("X-Call", strconv.Itoa(int(n)))
+		return c.SendString("articles " + c.Request.Header("Accept-Language"))
+	})
+	server.Get("/private", Cache(CacheConfig{Cache: cache}), func(c *Context) error {
+		calls.Add(1)
+		c.Response.SetHeader("Cache-Control", "private, max-age=60")
+		return c.SendString("private")
+	})
+	server.Get("/session", Cache(CacheConfig{Cache: cache}), func(c *Context) error {
+		calls.Add(1)
+		http.SetCookie(c.Response.HTTP(), &http.Cookie{Name: "session", Value: "1"})
+		return c.SendString("session")
+	})
+	server.Get("/fail", Cache(CacheConfig{Cache: cache}), func(c *Context) error {
+		calls.Add(1)
+		return &Error{StatusCode: http.StatusInternalServerError, Err: http.ErrHandlerTimeout}
+	})
+	server.Post("/articles", func(c *Context) error {
+		cache.InvalidateTags("articles")
+		return c.SendStatus(http.StatusCreated)
+	})
+	request := func(method, target string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		return server.Test().Request(req)
+	}
+
+	res := request(http.MethodGet, "/articles?b=2&a=1")
+	assert.Equal(t, res.Header().Get("X-Cache"), "MISS")
+	assert.Equal(t, res.Body.String(), "articles ")
+	res = request(http.MethodGet, "/articles?a=1&b=2")
+	assert.Equal(t, res.Code, http.StatusOK)
+	assert.Equal(t, res.Header().Get("X-Cache"), "HIT")
+	assert.Equal(t, res.Header().Get("X-Call"), "1")
+	assert.Equal(t, res.Header().Get("Age"), "0")
+	assert.Equal(t, res.Header().Get("Content-Type"), "text/plain; charset=utf-8")
+	assert.Equal(t, res.Body.String(), "articles ")
+
+	res = request(http.MethodHead, "/articles?a=1&b=2")
+	assert.Equal(t, res.Header().Get("X-Cache"), "HIT")
+	assert.Equal(t, res.Body.Len(), 0)
+
+	res = request(http.MethodGet, "/articles?a=1&b=2", "Accept-Language", "pt-BR")
+	assert.Equal(t, res.Header().Get("X-Cache"), "MISS")
+	assert.Equal(t, res.Body.String(), "articles pt-BR")
+	assert.Equal(t, request(http.MethodGet, "/articles?a=1&b=2", "Accept-Language", "pt-BR").Header().Get("X-Cache"), "HIT")
+	assert.Equal(t, cache.Len(), 2)
+
+	res = request(http.MethodGet, "/articles?a=1&b=2", "Authorization", "Bearer token")
+	assert.Empty(t, res.Header().Get("X-Cache"))
+	res = request(http.MethodGet, "/articles?a=1&b=2", "Cookie", "session=1")
+	assert.Empty(t, res.Header().Get("X-Cache"))
+	assert.Equal(t, calls.Load(), int32(4))
+
+	req := httptest.NewRequest(http.MethodGet, "/articles?a=1&b=2", nil)
+	req.Host = "other.example.com"
+	assert.Equal(t, server.Test().Request(req).Header().Get("X-Cache"), "MISS")
+	assert.Equal(t, cache.Len(), 3)
+	cache.Invalidate("GET other.example.com/articles?a=1&b=2")
+
+	assert.Equal(t, request(http.MethodPost, "/articles").Code, http.StatusCreated)
+	assert.Equal(t, cache.Len(), 0)
+	assert.Equal(t, request(http.MethodGet, "/articles?a=1&b=2").Header().Get("X-Cache"), "MISS")
+	cache.Invalidate("GET example.com/articles?a=1&b=2")
+	assert.Equal(t, cache.Len(), 0)
+
+	for _, path := range []string{"/private", "/session", "/fail"} {
+		request(http.MethodGet, path)
+		res = request(http.MethodGet, path)
+		assert.Equal(t, res.Header().Get("X-Cache"), "MISS", path)
+	}
+	assert.Equal(t, cache.Len(), 0)
+}
+
+func TestCacheControl(t *testing.T) {
+	cache := NewResponseCache(2)
+	server := New(0)
+	server.Get("/:id", Cache(CacheConfig{Cache: cache, TTL: time.Hour}), func(c *Context) error {
+		switch id := c.Params("id"); id {
+		case "expired":
+			c.Response.SetHeader("Cache-Control", "max-age=0")
+		case "vary":
+			c.Response.SetHeader("Vary", "*")
+		}
+		return c.SendString(c.Params("id"))
+	})
+	request := func(path string) *httptest.ResponseRecorder {
+		return server.Test().Request(httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	request("/expired")
+	request("/vary")
+	assert.Equal(t, cache.Len(), 0)
+
+	request("/a")
+	request("/b")
+	request("/a")
+	request("/c")
+	assert.Equal(t, cache.Len(), 2)
+	assert.Equal(t, request("/a").Header().Get("X-Cache"), "HIT")
+	assert.Equal(t, request("/b").Header().Get("X-Cache"), "MISS")
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	cache := NewResponseCache(0)
+	var calls atomic.Int32
+	refreshed := make(chan struct{}, 1)
+	server := New(0)
+	server.Get("/report", Cache(CacheConfig{Cache: cache}), func(c *Context) error {
+		n := calls.Add(1)
+		c.Response.SetHeader("Cache-Control", "max-age=0, stale-while-revalidate=60")
+		if n > 1 {
+			defer func() { refreshed <- struct{}{} }()
+		}
+		return c.SendString("report " + strconv.Itoa(int(n)))
+	})
+	request := func() *httptest.ResponseRecorder {
+		return server.Test().Request(httptest.NewRequest(http.MethodGet, "/report", nil))
+	}
+
+	assert.Equal(t, request().Body.String(), "report 1")
+	time.Sleep(10 * time.Millisecond)
+	res := request()
+	assert.Equal(t, res.Header().Get("X-Cache"), "STALE")
+	assert.Equal(t, res.Body.String(), "report 1")
+
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("the stale response was not revalidated")
+	}
+	for range 100 {
+		if res = request(); res.Body.String() == "report 2" {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.Equal(t, res.Body.String(), "report 2")
+}
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrNoCertificates is returned when a CertReloader is created without pairs.
+var ErrNoCertificates = errors.New("no certificates")
+
+// ErrNoTLSCertificate is returned by ListenTLS when no certificate is
+// configured and ServerOpts.SelfSignedTLS is not set.
+var ErrNoTLSCertificate = errors.New("no TLS certificate configured")
+
+// CertPair is the location of a PEM encoded certificate chain and its key.
+type CertPair struct {
+	CertFile, KeyFile string
+}
+
+// CertReloader serves TLS certificates loaded from disk, reloading them when
+// their files change or the process receives SIGHUP, so certificates can be
+// rotated without restarting. With several pairs, the certificate is chosen
+// by the server name (SNI) requested by the client, falling back to the first.
+//
+//	certs, err := i9.NewCertReloader(
+//		i9.CertPair{CertFile: "api.pem", KeyFile: "api-key.pem"},
+//		i9.CertPair{CertFile: "admin.pem", KeyFile: "admin-key.pem"},
+//	)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	go certs.Watch(ctx, time.Minute)
+//	server := nine.NewServer(443, i9.ServerOpts{TLSConfig: certs.TLSConfig()})
+//	log.Fatal(server.ListenTLS("", ""))
+type CertReloader struct {
+	pairs []CertPair
+
+	mu       sync.RWMutex
+	certs    []*tls.Certificate
+	modTimes []time.Time
+}
+
+// NewCertReloader loads the given pairs.
+func NewCertReloader(pairs ...CertPair) (*CertReloader, error) {
+	if len(pairs) == 0 {
+		return nil, ErrNoCertificates
+	}
+	r := &CertReloader{pairs: pairs}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads every pair from disk. The certificates in use are only
+// replaced when all of them load successfully.
+func (r *CertReloader) Reload() error {
+	certs := make([]*tls.Certificate, len(r.pairs))
+	modTimes := make([]time.Time, len(r.pairs))
+	for i, pair := range r.pairs {
+		cert, err := tls.LoadX509KeyPair(pair.CertFile, pair.KeyFile)
+		if err != nil {
+			return fmt.Errorf("load %s: %w", pair.CertFile, err)
+		}
+		certs[i] = &cert
+		modTimes[i] = pair.modTime()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.certs, r.modTimes = certs, modTimes
+	return nil
+}
+
+// GetCertificate returns the certificate for the client hello. It is meant
+// to be used as tls.Config.GetCertificate.
+func (r *CertReloader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.certs) > 1 {
+		for _, cert := range r.certs {
+			if hello.SupportsCertificate(cert) == nil {
+				return cert, nil
+			}
+		}
+	}
+	return r.certs[0], nil
+}
+
+// TLSConfig returns a tls.Config serving the certificates of r.
+func (r *CertReloader) TLSConfig() *tls.Config {
+	return &tls.Config{GetCertificate: r.GetCertificate}
+}
+
+// Watch reloads the certificates when the process receives SIGHUP or, when
+// interval is positive, when a file modification time changes. It blocks
+// until ctx is canceled. Failed reloads are logged and the previous
+// certificates are kept.
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	reload := func() {
+		if err := r.Reload(); err != nil {
+			log.Printf("nine: reload certificates: %v", err)
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			reload()
+		case <-tick:
+			if r.changed() {
+				reload()
+			}
+		}
+	}
+}
+
+func (r *CertReloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for i, pair := range r.pairs {
+		if !pair.modTime().Equal(r.modTimes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// modTime returns the latest modification time of the files of the pair.
+func (p CertPair) modTime() time.Time {
+	var latest time.Time
+	for _, file := range []string{p.CertFile, p.KeyFile} {
+		if info, err := os.Stat(file); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// SelfSignedCertificate generates an in-memory certificate for local
+// development, valid for a year for the given hosts, which default to
+// localhost, 127.0.0.1 and ::1. Browsers and clients will not trust it.
+func SelfSignedCertificate(hosts ...string) (tls.Certificate, error) {
+	if len(hosts) == 0 {
+		hosts = []string{"localhost", "127.0.0.1", "::1"}
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"nine development"}, CommonName: hosts[0]},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+// ensureCertificate configures an in-memory self-signed certificate, when
+// allowed, if ListenTLS is called without files and the TLS config has no
+// certificates.
+func (s *Server) ensureCertificate(certFile, keyFile string) error {
+	config := s.httpServer.TLSConfig
+	if certFile != "" || keyFile != "" ||
+		(config != nil && (len(config.Certificates) > 0 || config.GetCertificate != nil || config.GetConfigForClient != nil)) {
+		return nil
+	}
+	if !s.selfSignedTLS {
+		return ErrNoTLSCertificate
+	}
+	cert, err := SelfSignedCertificate()
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		config = new(tls.Config)
+	} else {
+		config = config.Clone()
+	}
+	config.Certificates = []tls.Certificate{cert}
+	s.httpServer.TLSConfig = config
+	log.Println("nine: no certificate configured, serving a self-signed development certificate for localhost")
+	return nil
+}
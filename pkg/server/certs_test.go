@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/i9si-sistemas/assert"
+)
+
+func writeCertPair(t *testing.T, cert tls.Certificate, pair CertPair) {
+	t.Helper()
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	assert.NoError(t, err)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key})
+	assert.NoError(t, os.WriteFile(pair.CertFile, certPEM, 0o600))
+	assert.NoError(t, os.WriteFile(pair.KeyFile, keyPEM, 0o600))
+}
+
+func TestCertReloader(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	api := CertPair{CertFile: filepath.Join(dir, "api.pem"), KeyFile: filepath.Join(dir, "api-key.pem")}
+	admin := CertPair{CertFile: filepath.Join(dir, "admin.pem"), KeyFile: filepath.Join(dir, "admin-key.pem")}
+	writeCertPair(t, ca.issue(t, &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "api v1"},
+		DNSNames: []string{"api.example.com"},
+	}), api)
+	writeCertPair(t, ca.issue(t, &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "admin"},
+		DNSNames: []string{"admin.example.com"},
+	}), admin)
+
+	_, err := NewCertReloader()
+	assert.Equal(t, err, ErrNoCertificates)
+	certs, err := NewCertReloader(api, admin)
+	assert.NoError(t, err)
+
+	commonName := func(serverName string) string {
+		cert, err := certs.GetCertificate(&tls.ClientHelloInfo{
+			ServerName:        serverName,
+			SupportedVersions: []uint16{tls.VersionTLS13},
+			SignatureSchemes:  []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256},
+		})
+		assert.NoError(t, err)
+		return cert.Leaf.Subject.CommonName
+	}
+
+	t.Run("SNI", func(t *testing.T) {
+		assert.Equal(t, commonName("api.example.com"), "api v1")
+		assert.Equal(t, commonName("admin.example.com"), "admin")
+		assert.Equal(t, commonName("unknown.example.com"), "api v1")
+	})
+
+	t.Run("ReloadOnChange", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go certs.Watch(ctx, 10*time.Millisecond)
+
+		writeCertPair(t, ca.issue(t, &x509.Certificate{
+			Subject:  pkix.Name{CommonName: "api v2"},
+			DNSNames: []string{"api.example.com"},
+		}), api)
+		future := time.Now().Add(time.Minute)
+		assert.NoError(t, os.Chtimes(api.CertFile, future, future))
+		assert.NoError(t, os.Chtimes(api.KeyFile, future, future))
+
+		deadline := time.Now().Add(5 * time.Second)
+		for commonName("api.example.com") != "api v2" && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.Equal(t, commonName("api.example.com"), "api v2")
+	})
+
+	t.Run("FailedReloadKeepsCertificates", func(t *testing.T) {
+		assert.NoError(t, os.WriteFile(admin.KeyFile, []byte("broken"), 0o600))
+		assert.NotNil(t, certs.Reload())
+		assert.Equal(t, commonName("admin.example.com"), "admin")
+	})
+}
+
+func TestListenTLSSelfSigned(t *testing.T) {
+	cert, err := SelfSignedCertificate()
+	assert.NoError(t, err)
+	assert.Equal(t, cert.Leaf.DNSNames, []string{"localhost"})
+	assert.Equal(t, len(cert.Leaf.IPAddresses), 2)
+
+	server := New("")
+	assert.Equal(t, server.ListenTLS("", ""), ErrNoTLSCertificate)
+
+	server = New("", ServerOpts{SelfSignedTLS: true})
+	startServer(t, server, func() error { return server.ListenTLS("", "") })
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+	res, err := client.Get("https://127.0.0.1:" + server.Port() + "/")
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	peer := res.TLS.PeerCertificates[0]
+	assert.Equal(t, peer.DNSNames, []string{"localhost"})
+	assert.NoError(t, peer.VerifyHostname("127.0.0.1"))
+}
@@ -0,0 +1,299 @@
+package server
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultCompressMinSize is the size, in bytes, below which responses are
+// not compressed, since compression would not pay off.
+const DefaultCompressMinSize = 1024
+
+// DefaultCompressContentTypes are the compressed media types. Images,
+// videos and archives are already compressed.
+var DefaultCompressContentTypes = []string{
+	"text/*",
+	"application/json",
+	"application/*+json",
+	"application/javascript",
+	"application/xml",
+	"application/*+xml",
+	"application/wasm",
+	"image/svg+xml",
+}
+
+// CompressConfig configures the Compress middleware.
+type CompressConfig struct {
+	// Level is the compression level, from flate.BestSpeed to
+	// flate.BestCompression. Zero uses the default level.
+	Level int
+	// MinSize defaults to DefaultCompressMinSize,
+	// a negative value compresses every response.
+	MinSize int
+	// ContentTypes lists the compressed media types, which may contain
+	// wildcards as in "text/*". Defaults to DefaultCompressContentTypes.
+	ContentTypes []string
+}
+
+// Compress compresses the responses with gzip or deflate, as negotiated
+// through the Accept-Encoding header and its q-values. Responses smaller
+// than the minimum size, of a media type out of the allowlist, already
+// encoded, partial or streamed as Server-Sent Events are sent as is.
+// Responses that could be compressed get a "Vary: Accept-Encoding" header.
+//
+//	server.Use(i9.Compress(i9.CompressConfig{Level: flate.BestSpeed}))
+func Compress(options ...CompressConfig) HandlerWithContext {
+	var config CompressConfig
+	if len(options) > 0 {
+		config = options[0]
+	}
+	settings := newCompressSettings(config)
+	return func(c *Context) error {
+		w := settings.newWriter(c.Response.HTTP(), c.Request.Header("Accept-Encoding"))
+		c.Response.ChangeResponseWriter(w)
+		c.Next()
+		return w.Close()
+	}
+}
+
+type compressSettings struct {
+	minSize      int
+	contentTypes []string
+	pools        map[string]*sync.Pool
+}
+
+// encoder is implemented by the gzip and zlib writers.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+func newCompressSettings(config CompressConfig) *compressSettings {
+	level := config.Level
+	if level == 0 || level < flate.HuffmanOnly || level > flate.BestCompression {
+		level = flate.DefaultCompression
+	}
+	settings := &compressSettings{
+		minSize:      config.MinSize,
+		contentTypes: config.ContentTypes,
+		pools: map[string]*sync.Pool{
+			"gzip": {New: func() any {
+				w, _ := gzip.NewWriterLevel(io.Discard, level)
+				return w
+			}},
+			"deflate": {New: func() any {
+				w, _ := zlib.NewWriterLevel(io.Discard, level)
+				return w
+			}},
+		},
+	}
+	if settings.minSize == 0 {
+		settings.minSize = DefaultCompressMinSize
+	}
+	if settings.contentTypes == nil {
+		settings.contentTypes = DefaultCompressContentTypes
+	}
+	return settings
+}
+
+func (s *compressSettings) newWriter(w http.ResponseWriter, acceptEncoding string) *compressWriter {
+	cw := &compressWriter{
+		wrappedWriter: wrappedWriter{ResponseWriter: w},
+		settings:      s,
+		encoding:      negotiateEncoding(acceptEncoding),
+	}
+	cw.beforeFlush = cw.release
+	return cw
+}
+
+// negotiateEncoding returns the supported encoding with the highest
+// q-value in an Accept-Encoding header, gzip on ties, or "" for none.
+func negotiateEncoding(header string) string {
+	q := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		value := 1.0
+		if key, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.EqualFold(strings.TrimSpace(key), "q") {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				continue
+			}
+			value = parsed
+		}
+		q[name] = value
+	}
+	best, bestQ := "", 0.0
+	for _, encoding := range []string{"gzip", "deflate"} {
+		value, ok := q[encoding]
+		if !ok {
+			value, ok = q["*"]
+		}
+		if ok && value > bestQ {
+			best, bestQ = encoding, value
+		}
+	}
+	return best
+}
+
+// compressWriter buffers the start of the response until it reaches the
+// minimum size, or the handler ends or flushes, to decide whether to
+// compress it. The headers are written along with that decision.
+type compressWriter struct {
+	wrappedWriter
+	settings *compressSettings
+	encoding string
+
+	status  int
+	started bool
+	buf     []byte
+	enc     encoder
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.started || w.status != 0 {
+		return
+	}
+	if code < http.StatusOK {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+	if !bodyAllowed(code) {
+		w.start()
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.started {
+		if w.enc != nil {
+			return w.enc.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.settings.minSize {
+		if err := w.start(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// start decides whether to compress, writes the headers and the buffered body.
+func (w *compressWriter) start() error {
+	w.started = true
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 && bodyAllowed(w.status) {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if w.compressible() {
+		addVary(h, "Accept-Encoding")
+		if w.encoding != "" && len(w.buf) > 0 && len(w.buf) >= w.settings.minSize {
+			h.Set("Content-Encoding", w.encoding)
+			h.Del("Content-Length")
+			// The compressed body differs from the one a strong ETag was computed for.
+			if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+				h.Set("ETag", "W/"+etag)
+			}
+			w.enc = w.settings.pools[w.encoding].Get().(encoder)
+			w.enc.Reset(w.ResponseWriter)
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// compressible reports whether the response could be compressed,
+// whatever the client accepts.
+func (w *compressWriter) compressible() bool {
+	h := w.Header()
+	if !bodyAllowed(w.status) || w.status == http.StatusPartialContent ||
+		h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" ||
+		strings.Contains(strings.ToLower(h.Get("Cache-Control")), "no-transform") {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil || mediaType == "text/event-stream" {
+		return false
+	}
+	for _, pattern := range w.settings.contentTypes {
+		if ok, _ := path.Match(pattern, mediaType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Close writes what is left of the response and releases the encoder.
+func (w *compressWriter) Close() error {
+	if !w.started && w.status != 0 {
+		if err := w.start(); err != nil {
+			return err
+		}
+	}
+	if w.enc == nil {
+		return nil
+	}
+	err := w.enc.Close()
+	w.enc.Reset(io.Discard)
+	w.settings.pools[w.encoding].Put(w.enc)
+	w.enc = nil
+	return err
+}
+
+// release writes the buffered body on a flush, so streamed responses are
+// never held back.
+func (w *compressWriter) release() {
+	if !w.started {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.start()
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+}
+
+func bodyAllowed(status int) bool {
+	return status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// addVary adds value to the Vary header, unless it is listed already.
+func addVary(h http.Header, value string) {
+	for _, line := range h.Values("Vary") {
+		for _, v := range strings.Split(line, ",") {
+			if v = strings.TrimSpace(v); v == "*" || strings.EqualFold(v, value) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", value)
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/i9si-sistemas/assert"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	for header, expected := range map[string]string{
+		"":                            "",
+		"gzip":                        "gzip",
+		"deflate":                     "deflate",
+		"gzip, deflate, br":           "gzip",
+		"deflate;q=1, gzip;q=0.5":     "deflate",
+		"gzip;q=0, deflate;q=0.1":     "deflate",
+		"gzip;q=0":                    "",
+		"*":                           "gzip",
+		"*;q=0.2, gzip;q=0":           "deflate",
+		"br, identity":                "",
+		"GZIP ; Q=0.8 , deflate;q=.9": "deflate",
+		"gzip;q=abc":                  "",
+	} {
+		assert.Equal(t, negotiateEncoding(header), expected, header)
+	}
+}
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat(`{"name":"nine"},`, 200)
+	server := New(0)
+	server.Use(Compress())
+	server.Get("/json", func(c *Context) error {
+		c.Response.SetHeader("Content-Length", "3200")
+		c.Response.SetHeader("Content-Type", "application/json")
+		return c.SendString(large)
+	})
+	server.Get("/small", func(c *Context) error {
+		return c.SendString("tiny")
+	})
+	server.Get("/image", func(c *Context) error {
+		c.Response.SetHeader("Content-Type", "image/png")
+		return c.Send(bytes.Repeat([]byte{0}, 4096))
+	})
+	server.Get("/encoded", func(c *Context) error {
+		c.Response.SetHeader("Content-Encoding", "br")
+		return c.SendString(large)
+	})
+	server.Get("/chunks", func(c *Context) error {
+		c.Response.SetHeader("Content-Type", "text/plain")
+		for range 100 {
+			c.Response.HTTP().Write([]byte("0123456789abcdef"))
+		}
+		return nil
+	})
+	server.Get("/empty", func(c *Context) error {
+		return c.SendStatus(http.StatusNoContent)
+	})
+	server.Get("/fail", func(c *Context) error {
+		return &Error{StatusCode: http.StatusBadRequest, Err: io.ErrUnexpectedEOF}
+	})
+	request := func(path, encoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", encoding)
+		return server.Test().Request(req)
+	}
+
+	res := request("/json", "gzip")
+	assert.Equal(t, res.Header().Get("Content-Encoding"), "gzip")
+	assert.Equal(t, res.Header().Get("Vary"), "Accept-Encoding")
+	assert.Empty(t, res.Header().Get("Content-Length"))
+	reader, err := gzip.NewReader(res.Body)
+	assert.NoError(t, err)
+	body, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, string(body), large)
+
+	res = request("/chunks", "deflate")
+	assert.Equal(t, res.Header().Get("Content-Encoding"), "deflate")
+	zr, err := zlib.NewReader(res.Body)
+	assert.NoError(t, err)
+	body, err = io.ReadAll(zr)
+	assert.NoError(t, err)
+	assert.Equal(t, string(body), strings.Repeat("0123456789abcdef", 100))
+
+	res = request("/json", "")
+	assert.Empty(t, res.Header().Get("Content-Encoding"))
+	assert.Equal(t, res.Header().Get("Vary"), "Accept-Encoding")
+	assert.Equal(t, res.Body.String(), large)
+
+	res = request("/small", "gzip")
+	assert.Empty(t, res.Header().Get("Content-Encoding"))
+	assert.Equal(t, res.Header().Get("Content-Type"), "text/plain; charset=utf-8")
+	assert.Equal(t, res.Body.String(), "tiny")
+
+	res = request("/image", "gzip")
+	assert.Empty(t, res.Header().Get("Content-Encoding"))
+	assert.Empty(t, res.Header().Get("Vary"))
+	assert.Equal(t, res.Body.Len(), 4096)
+
+	res = request("/encoded", "gzip")
+	assert.Equal(t, res.Header().Get("Content-Encoding"), "br")
+	assert.Equal(t, res.Body.String(), large)
+
+	res = request("/empty", "gzip")
+	assert.Equal(t, res.Code, http.StatusNoContent)
+	assert.Empty(t, res.Header().Get("Content-Encoding"))
+
+	res = request("/fail", "gzip")
+	assert.Equal(t, res.Code, http.StatusBadRequest)
+	assert.Equal(t, res.Body.String(), io.ErrUnexpectedEOF.Error()+"\n")
+}
+
+func TestCompressStreaming(t *testing.T) {
+	server := New(0)
+	server.Use(Compress(CompressConfig{MinSize: -1}))
+	server.Get("/events", func(c *Context) error {
+		return c.SSE(func(stream *EventStream) error {
+			return stream.Send("", "", strings.Repeat("x", 2048))
+		})
+	})
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	res := server.Test().Request(req)
+	assert.Empty(t, res.Header().Get("Content-Encoding"))
+	assert.True(t, strings.Contains(res.Body.String(), "data: xxx"))
+	assert.True(t, res.Flushed)
+}
+
+func TestServeFilesCompression(t *testing.T) {
+	dir := t.TempDir()
+	content := strings.Repeat("static content ", 100)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "app.js"), []byte(content), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "logo.png"), []byte("\x89PNG\r\n\x1a\n"), 0o644))
+	server := New(0)
+	server.ServeFiles("/", dir)
+	request := func(path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header = header
+		return server.Test().Request(req)
+	}
+
+	res := request("/app.js", http.Header{"Accept-Encoding": {"gzip"}})
+	assert.Equal(t, res.Header().Get("Content-Encoding"), "gzip")
+	assert.Equal(t, res.Header().Get("Vary"), "Accept-Encoding")
+	assert.Empty(t, res.Header().Get("Content-Length"))
+
+	res = request("/app.js", http.Header{"Accept-Encoding": {"gzip"}, "Range": {"bytes=0-5"}})
+	assert.Equal(t, res.Code, http.StatusPartialContent)
+	assert.Empty(t, res.Header().Get("Content-Encoding"))
+	assert.Equal(t, res.Body.String(), "static")
+
+	res = request("/logo.png", http.Header{"Accept-Encoding": {"gzip"}})
+	assert.Empty(t, res.Header().Get("Content-Encoding"))
+
+	res = request("/app.js", http.Header{"Accept-Encoding": {"identity"}})
+	assert.Empty(t, res.Header().Get("Content-Encoding"))
+	assert.Equal(t, res.Body.String(), content)
+}
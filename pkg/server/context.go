@@ -6,10 +6,10 @@ import (
 	"fmt"
 	"mime/multipart"
 	"net/http"
-	"os"
 	"reflect"
 	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/i9si-sistemas/nine/internal/json"
 )
@@ -170,15 +170,6 @@ func (c *Context) SendString(s string) error {
 	return c.Send([]byte(s))
 }
 
-// SendFile sends a file as the response body.
-func (c *Context) SendFile(filePath string) error {
-	b, err := os.ReadFile(filePath)
-	if err != nil {
-		return err
-	}
-	return c.Send(b)
-}
-
 // BodyParser parses the request body into the provided struct pointer.
 func (c *Context) BodyParser(v any) error {
 	return json.NewDecoder(c.Request.Body()).Decode(v)
@@ -220,23 +211,66 @@ func (c *Context) Method() string {
 }
 
 // IP returns the IP address of the client making the request.
+//
+// Forwarding headers (Forwarded, X-Forwarded-For and X-Real-IP) are only
+// honored when the request comes from one of the ServerOpts.TrustedProxies,
+// and the chain is walked from right to left until the first untrusted
+// address. Otherwise the address of the connection is returned, without its port.
 func (c *Context) IP() string {
-	if ip := c.Request.Header("X-Real-IP"); ip != "" {
-		return ip
+	hops, client := forwardingChain(c.Request.HTTP())
+	return hops[client].String()
+}
+
+// IPs returns the trusted chain of IP addresses of the request, starting
+// with the client, followed by the proxies it went through, and ending with
+// the address of the connection.
+func (c *Context) IPs() []string {
+	hops, client := forwardingChain(c.Request.HTTP())
+	ips := make([]string, 0, len(hops)-client)
+	for _, hop := range hops[client:] {
+		ips = append(ips, hop.String())
 	}
-	if ip := c.Request.Header("X-Forwarded-For"); ip != "" {
-		return ip
+	return ips
+}
+
+// Protocol returns the scheme used by the client, "http" or "https".
+// It honors the Forwarded and X-Forwarded-Proto headers set by trusted proxies.
+func (c *Context) Protocol() string {
+	r := c.Request.HTTP()
+	hops, client := forwardingChain(r)
+	if proto := hops[client].proto; proto != "" {
+		return proto
+	}
+	if serverFromRequest(r).trustsProxy(hops[len(hops)-1].addr) {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+			return strings.ToLower(lastComma(proto))
+		}
 	}
-	return c.Request.HTTP().RemoteAddr
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
 }
 
-// IPs returns the IP addresses of the clients making the request.
-func (c *Context) IPs() []string {
-	ips := c.Request.Header("X-Forwarded-For")
-	if ips == "" {
-		return []string{c.IP()}
+// Hostname returns the host requested by the client, without the port.
+// It honors the Forwarded and X-Forwarded-Host headers set by trusted proxies.
+func (c *Context) Hostname() string {
+	return stripPort(c.host())
+}
+
+// host returns the host requested by the client, with its port if any.
+func (c *Context) host() string {
+	r := c.Request.HTTP()
+	hops, client := forwardingChain(r)
+	if host := hops[client].host; host != "" {
+		return host
+	}
+	if serverFromRequest(r).trustsProxy(hops[len(hops)-1].addr) {
+		if host := r.Header.Get("X-Forwarded-Host"); host != "" {
+			return lastComma(host)
+		}
 	}
-	return splitComma(ips)
+	return r.Host
 }
 
 // Body returns the request body as a byte slice.
@@ -302,6 +336,55 @@ func (c *Context) JSON(data any) error {
 	return c.Response.JSON(payload)
 }
 
+// XML sends an XML-encoded response.
+func (c *Context) XML(data any, opts ...XMLOptions) error {
+	return c.Response.XML(data, opts...)
+}
+
+// Render renders the named template through the server's ViewEngine
+// and sends it as an HTML response. When the template fails, an error
+// page is sent with the 500 status code instead.
+//
+//	server.Get("/admin", func(c *i9.Context) error {
+//		return c.Render("admin/index", i9.JSON{"title": "Admin"}, "layouts/main")
+//	})
+func (c *Context) Render(name string, data any, layout ...string) error {
+	var views ViewEngine
+	if s := serverFromRequest(c.Request.HTTP()); s != nil {
+		views = s.views
+	}
+	if views == nil {
+		return ErrViewsNotConfigured
+	}
+	buf := new(bytes.Buffer)
+	if err := views.Render(buf, name, data, layout...); err != nil {
+		buf.Reset()
+		engine, _ := views.(*Views)
+		if err := engine.renderError(buf, err); err != nil {
+			return err
+		}
+		c.Response.Status(http.StatusInternalServerError)
+	}
+	return c.Response.send("text/html; charset=utf-8", buf.Bytes())
+}
+
+// Next runs the rest of the middleware chain and the route handler, so a
+// middleware can act on the response once it has been written. Middlewares
+// that do not call it continue the chain when they return. It does nothing
+// in route handlers.
+//
+//	server.Use(func(c *i9.Context) error {
+//		start := time.Now()
+//		c.Next()
+//		slog.Info("request", "path", c.Path(), "latency", time.Since(start))
+//		return nil
+//	})
+func (c *Context) Next() {
+	if c.Response.next != nil {
+		c.Response.next()
+	}
+}
+
 func (c *Context) pathRegistred() string {
 	return c.Request.PathRegistred()
 }
@@ -79,8 +79,8 @@ func TestIPRetrieval(t *testing.T) {
 	res := httptest.NewRecorder()
 	c := NewContext(context.Background(), req, res)
 
-	assert.Equal(t, c.IP(), "192.168.1.1")
-	assert.Equal(t, c.IPs(), []string{"203.0.113.1", "203.0.113.2"})
+	assert.Equal(t, c.IP(), "192.0.2.1")
+	assert.Equal(t, c.IPs(), []string{"192.0.2.1"})
 }
 
 func TestQueryWithDefault(t *testing.T) {
@@ -200,3 +200,19 @@ func TestSendString(t *testing.T) {
 	assert.Equal(t, res.Header().Get("Content-Type"), "text/plain; charset=utf-8")
 	assert.Equal(t, res.Body.String(), "Hello World!")
 }
+
+func TestContextXML(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	res := httptest.NewRecorder()
+	c := NewContext(context.Background(), req, res)
+
+	err := c.Status(http.StatusCreated).XML(JSON{
+		"@id":  1,
+		"name": "gopher",
+	}, XMLOptions{Root: "user", Declaration: true})
+	assert.NoError(t, err)
+	assert.Equal(t, res.Code, http.StatusCreated)
+	assert.Equal(t, res.Header().Get("Content-Type"), "application/xml; charset=utf-8")
+	expected := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<user id="1"><name>gopher</name></user>`
+	assert.Equal(t, res.Body.String(), expected)
+}
@@ -3,63 +3,72 @@ package server
 import (
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/i9si-sistemas/stringx"
 )
 
 type CorsConfig struct {
-	AllowOrigins     []string
-	AllowMethods     []string
-	AllowHeaders     []string
+	// AllowOrigins lists the allowed origins, "*" for any. An origin may
+	// hold a wildcard for its subdomains, as "https://*.example.com",
+	// which does not match "https://example.com".
+	AllowOrigins []string
+	// AllowOriginFunc allows the origins it returns true for,
+	// besides AllowOrigins.
+	AllowOriginFunc func(origin string) bool
+	// AllowMethods defaults to the methods of DefaultCorsConfig.
+	AllowMethods []string
+	// AllowHeaders defaults to the headers of DefaultCorsConfig,
+	// "*" allows any header.
+	AllowHeaders []string
+	// ExposeHeaders lists the response headers readable by scripts,
+	// besides the CORS-safelisted ones.
+	ExposeHeaders    []string
 	AllowCredentials bool
-	MaxAge           int64
+	// AllowPrivateNetwork allows public websites to request the server
+	// when it runs on a private network, as the Private Network Access
+	// preflight requests ask.
+	AllowPrivateNetwork bool
+	MaxAge              int64
 }
 
-func Cors(server *Server, options ...CorsConfig) HandlerWithContext {
+// Cors answers the CORS preflight requests of the routes of router, a
+// server or a route group, and sets the CORS headers of their responses.
+// Preflight requests are validated: their origin, requested method and
+// headers must be allowed, or they get no CORS headers. Routes follow the
+// configuration of their innermost group, else the one of the server.
+//
+//	i9.Cors(server, i9.CorsConfig{AllowOrigins: []string{"https://*.example.com"}})
+//	partners := server.Group("/partners")
+//	i9.Cors(partners, i9.CorsConfig{AllowOriginFunc: partnerOrigins.Contains})
+//
+// The middleware returned applies the configuration to the routes it is
+// given to.
+func Cors(router RouteManager, options ...CorsConfig) HandlerWithContext {
 	config := DefaultCorsConfig()
 
 	if len(options) > 0 {
 		config = options[0]
 	}
-
-	setCorsHeaders := func(c *Context) {
-		origin := c.Header("Origin")
-		allowedOrigin := ""
-
-		for _, allowed := range config.AllowOrigins {
-			if allowed == "*" {
-				allowedOrigin = "*"
-				break
-			}
-			if allowed == origin {
-				allowedOrigin = origin
-				break
-			}
-		}
-		c.Response.SetHeader("Access-Control-Allow-Origin", allowedOrigin)
-
-		if config.AllowCredentials && allowedOrigin != "*" {
-			c.Response.SetHeader("Access-Control-Allow-Credentials", "true")
-		}
-		convert := func(s []string) stringx.String { return stringx.ConvertStrings(s...).Join(",") }
-		c.Response.SetHeader("Access-Control-Allow-Methods", convert(config.AllowMethods).String())
-		c.Response.SetHeader("Access-Control-Allow-Headers", convert(config.AllowHeaders).String())
-		c.Response.SetHeader("Access-Control-Max-Age", fmt.Sprint(config.MaxAge))
+	defaults := DefaultCorsConfig()
+	if config.AllowMethods == nil {
+		config.AllowMethods = defaults.AllowMethods
 	}
-
-	handler := func(c *Context) error {
-		setCorsHeaders(c)
-		if c.Method() == http.MethodOptions {
-			return c.SendStatus(http.StatusNoContent)
-		}
-		return nil
+	if config.AllowHeaders == nil {
+		config.AllowHeaders = defaults.AllowHeaders
 	}
+	policy := newCorsPolicy(config)
 
-	server.EnableCors(HandlerWithContext(handler))
+	if enabler, ok := router.(corsEnabler); ok {
+		enabler.enableCors("", corsRoute{preflight: policy.preflight, actual: policy.actual})
+	}
 	return func(c *Context) error {
-		setCorsHeaders(c)
-		return nil
+		if c.Method() == http.MethodOptions && c.Header("Access-Control-Request-Method") != "" {
+			return policy.preflight(c)
+		}
+		return policy.actual(c)
 	}
 }
 
@@ -72,3 +81,166 @@ func DefaultCorsConfig() CorsConfig {
 		MaxAge:           int64((24 * time.Hour).Seconds()),
 	}
 }
+
+// corsRoute holds the CORS handlers of the routes under a base path.
+type corsRoute struct {
+	preflight, actual HandlerWithContext
+}
+
+// corsEnabler is implemented by the servers and route groups,
+// which register the CORS handlers of their routes.
+type corsEnabler interface {
+	enableCors(basePath string, route corsRoute)
+}
+
+type corsPolicy struct {
+	config             CorsConfig
+	anyOrigin          bool
+	anyHeader          bool
+	origins, wildcards []string
+	methods, headers   string
+	expose, maxAge     string
+}
+
+func newCorsPolicy(config CorsConfig) *corsPolicy {
+	convert := func(s []string) string { return stringx.ConvertStrings(s...).Join(",").String() }
+	p := &corsPolicy{
+		config:  config,
+		methods: convert(config.AllowMethods),
+		headers: convert(config.AllowHeaders),
+		expose:  convert(config.ExposeHeaders),
+		maxAge:  fmt.Sprint(config.MaxAge),
+	}
+	for _, origin := range config.AllowOrigins {
+		origin = strings.ToLower(origin)
+		switch {
+		case origin == "*":
+			p.anyOrigin = true
+		case strings.Contains(origin, "*"):
+			p.wildcards = append(p.wildcards, origin)
+		default:
+			p.origins = append(p.origins, origin)
+		}
+	}
+	p.anyHeader = slices.Contains(config.AllowHeaders, "*")
+	return p
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin of a request origin,
+// or "" when it is not allowed.
+func (p *corsPolicy) allowOrigin(origin string) string {
+	if origin == "" {
+		if p.anyOrigin {
+			return "*"
+		}
+		return ""
+	}
+	if p.anyOrigin && !p.config.AllowCredentials {
+		return "*"
+	}
+	lower := strings.ToLower(origin)
+	if slices.Contains(p.origins, lower) {
+		return origin
+	}
+	for _, pattern := range p.wildcards {
+		if matchOriginPattern(pattern, lower) {
+			return origin
+		}
+	}
+	if p.config.AllowOriginFunc != nil && p.config.AllowOriginFunc(origin) {
+		return origin
+	}
+	if p.anyOrigin {
+		return "*"
+	}
+	return ""
+}
+
+// matchOriginPattern matches an origin against a pattern holding a "*"
+// for one or more subdomain labels.
+func matchOriginPattern(pattern, origin string) bool {
+	prefix, suffix, _ := strings.Cut(pattern, "*")
+	if len(origin) <= len(prefix)+len(suffix) || !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+		return false
+	}
+	labels := origin[len(prefix) : len(origin)-len(suffix)]
+	return !strings.ContainsAny(labels, "/:@") && !strings.HasPrefix(labels, ".") && !strings.HasSuffix(labels, ".")
+}
+
+// setOrigin sets the allowed origin and the credentials, and returns
+// false when the origin of a CORS request is not allowed.
+func (p *corsPolicy) setOrigin(c *Context) bool {
+	if !p.anyOrigin || p.config.AllowCredentials {
+		addVary(c.Response.HTTP().Header(), "Origin")
+	}
+	origin := c.Header("Origin")
+	allowed := p.allowOrigin(origin)
+	if allowed == "" {
+		return origin == ""
+	}
+	c.Response.SetHeader("Access-Control-Allow-Origin", allowed)
+	if p.config.AllowCredentials && allowed != "*" {
+		c.Response.SetHeader("Access-Control-Allow-Credentials", "true")
+	}
+	return true
+}
+
+func (p *corsPolicy) actual(c *Context) error {
+	h := c.Response.HTTP().Header()
+	for _, name := range []string{
+		"Access-Control-Allow-Origin", "Access-Control-Allow-Credentials", "Access-Control-Allow-Methods",
+		"Access-Control-Allow-Headers", "Access-Control-Expose-Headers",
+	} {
+		h.Del(name)
+	}
+	if p.setOrigin(c) {
+		c.Response.SetHeader("Access-Control-Allow-Methods", p.methods)
+		c.Response.SetHeader("Access-Control-Allow-Headers", p.headers)
+		if p.expose != "" {
+			c.Response.SetHeader("Access-Control-Expose-Headers", p.expose)
+		}
+	}
+	return nil
+}
+
+// preflight answers a preflight request, with no CORS headers when its
+// origin, requested method, headers or private network access are not
+// allowed, so the browser refuses the actual request.
+func (p *corsPolicy) preflight(c *Context) error {
+	h := c.Response.HTTP().Header()
+	addVary(h, "Access-Control-Request-Method")
+	addVary(h, "Access-Control-Request-Headers")
+	if p.setOrigin(c) && p.validPreflight(c) {
+		c.Response.SetHeader("Access-Control-Allow-Methods", p.methods)
+		c.Response.SetHeader("Access-Control-Allow-Headers", p.headers)
+		c.Response.SetHeader("Access-Control-Max-Age", p.maxAge)
+		if p.config.AllowPrivateNetwork && c.Header("Access-Control-Request-Private-Network") == "true" {
+			c.Response.SetHeader("Access-Control-Allow-Private-Network", "true")
+		}
+	} else {
+		h.Del("Access-Control-Allow-Origin")
+		h.Del("Access-Control-Allow-Credentials")
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+func (p *corsPolicy) validPreflight(c *Context) bool {
+	if method := c.Header("Access-Control-Request-Method"); method != "" && !slices.Contains(p.config.AllowMethods, method) {
+		return false
+	}
+	if c.Header("Access-Control-Request-Private-Network") == "true" && !p.config.AllowPrivateNetwork {
+		return false
+	}
+	if p.anyHeader && !p.config.AllowCredentials {
+		return true
+	}
+	for _, name := range strings.Split(c.Header("Access-Control-Request-Headers"), ",") {
+		name = strings.TrimSpace(name)
+		if name != "" && !slices.ContainsFunc(p.config.AllowHeaders, func(allowed string) bool {
+			return strings.EqualFold(allowed, name)
+		}) {
+			return false
+		}
+	}
+	return true
+}
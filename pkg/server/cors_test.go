@@ -151,3 +151,139 @@ func TestDefaultCorsConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchOriginPattern(t *testing.T) {
+	for origin, expected := range map[string]bool{
+		"https://app.example.com":       true,
+		"https://a.b.example.com":       true,
+		"https://example.com":           false,
+		"http://app.example.com":        false,
+		"https://app.example.com:8080":  false,
+		"https://evil.com/.example.com": false,
+		"https://evilexample.com":       false,
+		"https://.example.com":          false,
+	} {
+		assert.Equal(t, matchOriginPattern("https://*.example.com", origin), expected, origin)
+	}
+	assert.True(t, matchOriginPattern("http://*.localhost:3000", "http://app.localhost:3000"))
+}
+
+func TestCorsOrigins(t *testing.T) {
+	server := New(0)
+	Cors(server, CorsConfig{
+		AllowOrigins:     []string{"https://*.example.com", "https://Partner.com"},
+		AllowOriginFunc:  func(origin string) bool { return origin == "https://trusted.dev" },
+		ExposeHeaders:    []string{"X-Total-Count", "Link"},
+		AllowCredentials: true,
+	})
+	server.Get("/items", func(c *Context) error {
+		return c.SendString("items")
+	})
+	request := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/items", nil)
+		req.Header.Set("Origin", origin)
+		return server.Test().Request(req)
+	}
+
+	for _, origin := range []string{"https://app.example.com", "https://partner.com", "https://trusted.dev"} {
+		res := request(origin)
+		assert.Equal(t, res.Header().Get("Access-Control-Allow-Origin"), origin)
+		assert.Equal(t, res.Header().Get("Access-Control-Allow-Credentials"), "true")
+		assert.Equal(t, res.Header().Get("Access-Control-Expose-Headers"), "X-Total-Count,Link")
+		assert.Equal(t, res.Header().Get("Vary"), "Origin")
+	}
+	res := request("https://example.com.evil.io")
+	assert.Equal(t, res.Code, http.StatusOK)
+	assert.Empty(t, res.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, res.Header().Get("Access-Control-Expose-Headers"))
+	assert.Equal(t, res.Header().Get("Vary"), "Origin")
+}
+
+func TestCorsPreflight(t *testing.T) {
+	server := New(0)
+	Cors(server, CorsConfig{
+		AllowOrigins:        []string{"https://app.example.com"},
+		AllowMethods:        []string{"GET", "PUT"},
+		AllowHeaders:        []string{"Content-Type", "X-Api-Key"},
+		AllowPrivateNetwork: true,
+		MaxAge:              600,
+	})
+	server.Put("/items/:id", func(c *Context) error {
+		return c.SendStatus(http.StatusNoContent)
+	})
+	preflight := func(method, headers string, privateNetwork bool) http.Header {
+		req := httptest.NewRequest(http.MethodOptions, "/items/1", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", method)
+		req.Header.Set("Access-Control-Request-Headers", headers)
+		if privateNetwork {
+			req.Header.Set("Access-Control-Request-Private-Network", "true")
+		}
+		res := server.Test().Request(req)
+		assert.Equal(t, res.Code, http.StatusNoContent)
+		return res.Header()
+	}
+
+	h := preflight("PUT", "content-type, x-api-key", true)
+	assert.Equal(t, h.Get("Access-Control-Allow-Origin"), "https://app.example.com")
+	assert.Equal(t, h.Get("Access-Control-Allow-Methods"), "GET,PUT")
+	assert.Equal(t, h.Get("Access-Control-Allow-Headers"), "Content-Type,X-Api-Key")
+	assert.Equal(t, h.Get("Access-Control-Max-Age"), "600")
+	assert.Equal(t, h.Get("Access-Control-Allow-Private-Network"), "true")
+	assert.Equal(t, h.Values("Vary"), []string{"Access-Control-Request-Method", "Access-Control-Request-Headers", "Origin"})
+
+	for _, h := range []http.Header{
+		preflight("DELETE", "", false),
+		preflight("PUT", "X-Secret", false),
+	} {
+		assert.Empty(t, h.Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, h.Get("Access-Control-Allow-Methods"))
+	}
+}
+
+func TestCorsGroups(t *testing.T) {
+	server := New(0)
+	server.routes = append(server.routes, Router{pattern: "/legacy", handler: func(req *Request, res *Response) error {
+		return res.SendStatus(http.StatusOK)
+	}})
+	Cors(server, CorsConfig{AllowOrigins: []string{"https://app.example.com"}})
+	server.Get("/public", func(c *Context) error {
+		return c.SendString("public")
+	})
+	partners := server.Group("/partners")
+	Cors(partners, CorsConfig{
+		AllowOrigins:  []string{"https://partner.com"},
+		AllowMethods:  []string{"GET"},
+		ExposeHeaders: []string{"X-Partner"},
+	})
+	partners.Get("/orders", func(c *Context) error {
+		return c.SendString("orders")
+	})
+	server.routes = append(server.routes, Router{pattern: "OPTIONS /partners/orders", handler: func(req *Request, res *Response) error {
+		return res.SendStatus(http.StatusTeapot)
+	}})
+	partners.Get("/reports", func(c *Context) error {
+		return c.SendString("reports")
+	})
+	request := func(method, path, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		return server.Test().Request(req)
+	}
+
+	res := request(http.MethodOptions, "/public", "https://app.example.com")
+	assert.Equal(t, res.Header().Get("Access-Control-Allow-Origin"), "https://app.example.com")
+	res = request(http.MethodOptions, "/partners/reports", "https://app.example.com")
+	assert.Empty(t, res.Header().Get("Access-Control-Allow-Origin"))
+	res = request(http.MethodOptions, "/partners/reports", "https://partner.com")
+	assert.Equal(t, res.Header().Get("Access-Control-Allow-Origin"), "https://partner.com")
+	assert.Equal(t, res.Header().Get("Access-Control-Allow-Methods"), "GET")
+	assert.Equal(t, request(http.MethodOptions, "/partners/orders", "https://partner.com").Code, http.StatusTeapot)
+
+	res = request(http.MethodGet, "/partners/orders", "https://partner.com")
+	assert.Equal(t, res.Header().Get("Access-Control-Allow-Origin"), "https://partner.com")
+	assert.Equal(t, res.Header().Get("Access-Control-Expose-Headers"), "X-Partner")
+	res = request(http.MethodGet, "/public", "https://partner.com")
+	assert.Empty(t, res.Header().Get("Access-Control-Allow-Origin"))
+}
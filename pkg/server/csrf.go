@@ -0,0 +1,305 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors of the requests refused by CSRF, with status 403 Forbidden.
+var (
+	ErrInvalidCSRFToken   = errors.New("invalid CSRF token")
+	ErrCrossOriginRequest = errors.New("cross-origin request refused")
+)
+
+// Defaults of the CSRF middleware.
+const (
+	DefaultCSRFCookie    = "_csrf"
+	DefaultCSRFHeader    = "X-CSRF-Token"
+	DefaultCSRFFormField = "csrf_token"
+	DefaultCSRFMaxAge    = 12 * time.Hour
+)
+
+// CSRFStore keeps the tokens of the synchronizer token pattern on the
+// server, by session.
+type CSRFStore interface {
+	// Token returns the token of a session, or "" when it has none.
+	Token(ctx context.Context, session string) (string, error)
+	// SetToken stores the token of a session for ttl.
+	SetToken(ctx context.Context, session, token string, ttl time.Duration) error
+}
+
+// CSRFConfig configures the CSRF middleware.
+type CSRFConfig struct {
+	// Store switches to the synchronizer token pattern, where the tokens
+	// stay on the server and the cookie holds a session identifier.
+	// Without it, the cookie holds the token, which requests submit back:
+	// the double-submit cookie pattern.
+	Store CSRFStore
+	// Session identifies the session of a request in the Store, for
+	// instance from the session of the application. Without a session,
+	// requests are identified by an identifier kept in the cookie.
+	Session func(c *Context) string
+	// Cookie is the name of the cookie, DefaultCSRFCookie by default.
+	Cookie string
+	// CookiePath defaults to "/".
+	CookiePath   string
+	CookieDomain string
+	// CookieSameSite defaults to http.SameSiteLaxMode.
+	CookieSameSite http.SameSite
+	// MaxAge is the lifetime of the cookie and of the tokens.
+	// Defaults to DefaultCSRFMaxAge.
+	MaxAge time.Duration
+	// Header carries the token of unsafe requests, DefaultCSRFHeader
+	// by default, or else the FormField, DefaultCSRFFormField by default.
+	Header    string
+	FormField string
+	// TrustedOrigins lists the origins, as "https://app.example.com",
+	// allowed to send unsafe requests besides the server's one.
+	TrustedOrigins []string
+	// Skip reports whether a request is exempt from the protection.
+	// Defaults to SkipBearerAuth.
+	Skip func(c *Context) bool
+}
+
+// SkipBearerAuth exempts the requests authenticated by a bearer token,
+// which browsers never send on their own, unlike cookies.
+func SkipBearerAuth(c *Context) bool {
+	scheme, _, _ := strings.Cut(c.Request.Header("Authorization"), " ")
+	return strings.EqualFold(scheme, "Bearer")
+}
+
+type csrfTokenKey struct{}
+
+// CSRF protects against cross-site request forgery. Requests with an
+// unsafe method, such as POST, must come from the server's origin or a
+// trusted one, as told by their Origin header or, without it, by their
+// Referer, required over HTTPS. They must also submit the token of the
+// client, which Context.CSRFToken returns, in a header or a form field.
+// Refused requests get a 403 Forbidden *Error.
+//
+//	server.Use(i9.CSRF())
+//	server.Get("/profile", func(c *i9.Context) error {
+//		return c.Render("profile", i9.JSON{"csrf": c.CSRFToken()})
+//	})
+//
+//	<input type="hidden" name="csrf_token" value="{{.csrf}}">
+func CSRF(options ...CSRFConfig) HandlerWithContext {
+	var config CSRFConfig
+	if len(options) > 0 {
+		config = options[0]
+	}
+	if config.Cookie == "" {
+		config.Cookie = DefaultCSRFCookie
+	}
+	if config.CookiePath == "" {
+		config.CookiePath = "/"
+	}
+	if config.CookieSameSite == 0 {
+		config.CookieSameSite = http.SameSiteLaxMode
+	}
+	if config.MaxAge <= 0 {
+		config.MaxAge = DefaultCSRFMaxAge
+	}
+	if config.Header == "" {
+		config.Header = DefaultCSRFHeader
+	}
+	if config.FormField == "" {
+		config.FormField = DefaultCSRFFormField
+	}
+	if config.Skip == nil {
+		config.Skip = SkipBearerAuth
+	}
+	forbidden := func(err error) error {
+		return &Error{StatusCode: http.StatusForbidden, Err: err}
+	}
+
+	return func(c *Context) error {
+		if config.Skip(c) {
+			return nil
+		}
+		token, err := config.token(c)
+		if err != nil {
+			return err
+		}
+		c.Request.SetContext(context.WithValue(c.Request.Context(), csrfTokenKey{}, token))
+
+		switch c.Method() {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			return nil
+		}
+		if !config.sameOrigin(c) {
+			return forbidden(ErrCrossOriginRequest)
+		}
+		r := c.Request.HTTP()
+		submitted := r.Header.Get(config.Header)
+		if submitted == "" {
+			submitted = r.FormValue(config.FormField)
+		}
+		if subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) != 1 {
+			return forbidden(ErrInvalidCSRFToken)
+		}
+		return nil
+	}
+}
+
+// token returns the token of the client, issuing one when it has none.
+func (config *CSRFConfig) token(c *Context) (string, error) {
+	var value string
+	if cookie, err := c.Request.HTTP().Cookie(config.Cookie); err == nil && validCSRFToken(cookie.Value) {
+		value = cookie.Value
+	}
+	if config.Store == nil {
+		if value == "" {
+			value = newCSRFToken()
+			config.setCookie(c, value, false)
+		}
+		return value, nil
+	}
+
+	var session string
+	if config.Session != nil {
+		session = config.Session(c)
+	}
+	if session == "" {
+		if value == "" {
+			value = newCSRFToken()
+			config.setCookie(c, value, true)
+		}
+		session = value
+	}
+	ctx := c.Request.Context()
+	token, err := config.Store.Token(ctx, session)
+	if err != nil || token != "" {
+		return token, err
+	}
+	token = newCSRFToken()
+	return token, config.Store.SetToken(ctx, session, token, config.MaxAge)
+}
+
+// setCookie sets the cookie, which scripts read in the double-submit
+// cookie pattern to send the token in a header.
+func (config *CSRFConfig) setCookie(c *Context, value string, httpOnly bool) {
+	http.SetCookie(c.Response.HTTP(), &http.Cookie{
+		Name:     config.Cookie,
+		Value:    value,
+		Path:     config.CookiePath,
+		Domain:   config.CookieDomain,
+		MaxAge:   int(config.MaxAge.Seconds()),
+		Secure:   c.Protocol() == "https",
+		HttpOnly: httpOnly,
+		SameSite: config.CookieSameSite,
+	})
+}
+
+// sameOrigin checks the origin of an unsafe request by its Origin header,
+// or else its Referer, which HTTPS requests must have.
+func (config *CSRFConfig) sameOrigin(c *Context) bool {
+	source := c.Request.Header("Origin")
+	if source == "" {
+		source = c.Request.Header("Referer")
+		if source == "" {
+			return c.Protocol() != "https"
+		}
+	}
+	u, err := url.Parse(source)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return false
+	}
+	origin := u.Scheme + "://" + u.Host
+	if slices.Contains(config.TrustedOrigins, origin) {
+		return true
+	}
+	return strings.EqualFold(u.Scheme, c.Protocol()) && originHost(u.Scheme, u.Host) == originHost(c.Protocol(), c.host())
+}
+
+// originHost returns host in lower case, with the default port of scheme
+// when it has none.
+func originHost(scheme, host string) string {
+	if _, port, err := net.SplitHostPort(host); err == nil && port != "" {
+		return strings.ToLower(host)
+	}
+	port := "80"
+	if strings.EqualFold(scheme, "https") {
+		port = "443"
+	}
+	return strings.ToLower(net.JoinHostPort(strings.Trim(strings.TrimSuffix(host, ":"), "[]"), port))
+}
+
+func newCSRFToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func validCSRFToken(token string) bool {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	return err == nil && len(b) == 32
+}
+
+// CSRFToken returns the token set by the CSRF middleware, which forms
+// submit in a hidden field and scripts in a header.
+func (c *Context) CSRFToken() string {
+	return CSRFTokenFromContext(c.Request.Context())
+}
+
+// CSRFTokenFromContext returns the token stored in ctx by the CSRF
+// middleware, or "".
+func CSRFTokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(csrfTokenKey{}).(string)
+	return token
+}
+
+// MemoryCSRFStore is a CSRFStore for a single instance.
+type MemoryCSRFStore struct {
+	mu        sync.Mutex
+	tokens    map[string]csrfStoreEntry
+	lastSweep time.Time
+}
+
+type csrfStoreEntry struct {
+	token   string
+	expires time.Time
+}
+
+// NewMemoryCSRFStore returns an empty store.
+func NewMemoryCSRFStore() *MemoryCSRFStore {
+	return &MemoryCSRFStore{tokens: map[string]csrfStoreEntry{}, lastSweep: time.Now()}
+}
+
+// Token returns the token of a session, unless expired.
+func (s *MemoryCSRFStore) Token(_ context.Context, session string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.tokens[session]
+	if !ok || time.Now().After(entry.expires) {
+		return "", nil
+	}
+	return entry.token, nil
+}
+
+// SetToken stores a token, removing the expired ones every minute.
+func (s *MemoryCSRFStore) SetToken(_ context.Context, session, token string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.lastSweep) > time.Minute {
+		for key, entry := range s.tokens {
+			if now.After(entry.expires) {
+				delete(s.tokens, key)
+			}
+		}
+		s.lastSweep = now
+	}
+	s.tokens[session] = csrfStoreEntry{token: token, expires: now.Add(ttl)}
+	return nil
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/i9si-sistemas/assert"
+)
+
+func TestCSRFDoubleSubmit(t *testing.T) {
+	server := New(0)
+	server.Use(CSRF(CSRFConfig{TrustedOrigins: []string{"https://app.example.com"}}))
+	server.Get("/form", func(c *Context) error {
+		return c.SendString(c.CSRFToken())
+	})
+	server.Post("/form", func(c *Context) error {
+		return c.SendString("saved " + c.Request.HTTP().FormValue("name"))
+	})
+	request := func(req *http.Request, cookie *http.Cookie) *httptest.ResponseRecorder {
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		return server.Test().Request(req)
+	}
+
+	res := request(httptest.NewRequest(http.MethodGet, "/form", nil), nil)
+	cookies := res.Result().Cookies()
+	assert.Equal(t, len(cookies), 1)
+	cookie := cookies[0]
+	assert.Equal(t, cookie.Name, DefaultCSRFCookie)
+	assert.Equal(t, cookie.Value, res.Body.String())
+	assert.False(t, cookie.HttpOnly)
+	assert.Equal(t, cookie.SameSite, http.SameSiteLaxMode)
+	token := cookie.Value
+
+	res = request(httptest.NewRequest(http.MethodGet, "/form", nil), cookie)
+	assert.Equal(t, res.Body.String(), token)
+	assert.Empty(t, res.Header().Get("Set-Cookie"))
+
+	form := func(value string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/form", strings.NewReader(url.Values{"name": {"nine"}, DefaultCSRFFormField: {value}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+	res = request(form(token), cookie)
+	assert.Equal(t, res.Code, http.StatusOK)
+	assert.Equal(t, res.Body.String(), "saved nine")
+
+	req := httptest.NewRequest(http.MethodPost, "/form", nil)
+	req.Header.Set(DefaultCSRFHeader, token)
+	req.Header.Set("Origin", "https://app.example.com")
+	assert.Equal(t, request(req, cookie).Code, http.StatusOK)
+
+	res = request(form("forged"), cookie)
+	assert.Equal(t, res.Code, http.StatusForbidden)
+	assert.Equal(t, res.Body.String(), ErrInvalidCSRFToken.Error()+"\n")
+	assert.Equal(t, request(form(token), nil).Code, http.StatusForbidden)
+
+	req = form(token)
+	req.Header.Set("Origin", "https://evil.example.com")
+	res = request(req, cookie)
+	assert.Equal(t, res.Code, http.StatusForbidden)
+	assert.Equal(t, res.Body.String(), ErrCrossOriginRequest.Error()+"\n")
+	req = form(token)
+	req.Header.Set("Referer", "http://evil.example.com/page")
+	assert.Equal(t, request(req, cookie).Code, http.StatusForbidden)
+	req = form(token)
+	req.Header.Set("Referer", "http://example.com/form")
+	assert.Equal(t, request(req, cookie).Code, http.StatusOK)
+	req = form(token)
+	req.Header.Set("Origin", "http://example.com:8080")
+	assert.Equal(t, request(req, cookie).Code, http.StatusForbidden)
+	req = form(token)
+	req.Header.Set("Origin", "http://EXAMPLE.com:80")
+	assert.Equal(t, request(req, cookie).Code, http.StatusOK)
+	req = form(token)
+	req.Host = "example.com:8080"
+	req.Header.Set("Origin", "http://example.com:8080")
+	assert.Equal(t, request(req, cookie).Code, http.StatusOK)
+	req = form(token)
+	req.Host = "example.com:8080"
+	req.Header.Set("Origin", "http://example.com")
+	assert.Equal(t, request(req, cookie).Code, http.StatusForbidden)
+
+	req = httptest.NewRequest(http.MethodPost, "/form", nil)
+	req.Header.Set("Authorization", "Bearer api-token")
+	assert.Equal(t, request(req, nil).Code, http.StatusOK)
+}
+
+func TestCSRFSynchronizer(t *testing.T) {
+	store := NewMemoryCSRFStore()
+	server := New(0)
+	api := server.Group("/app", CSRF(CSRFConfig{
+		Store: store,
+		Session: func(c *Context) string {
+			return c.Request.Header("X-Session")
+		},
+		Skip: func(c *Context) bool { return false },
+	}))
+	api.Get("/form", func(c *Context) error {
+		return c.SendString(c.CSRFToken())
+	})
+	api.Post("/form", func(c *Context) error {
+		return c.SendStatus(http.StatusCreated)
+	})
+	request := func(method, token, session string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/app/form", nil)
+		req.Header.Set("X-Session", session)
+		if token != "" {
+			req.Header.Set(DefaultCSRFHeader, token)
+		}
+		return server.Test().Request(req)
+	}
+
+	res := request(http.MethodGet, "", "alice")
+	token := res.Body.String()
+	assert.NotEmpty(t, token)
+	assert.Empty(t, res.Header().Get("Set-Cookie"))
+	assert.Equal(t, request(http.MethodGet, "", "alice").Body.String(), token)
+	assert.NotEqual(t, request(http.MethodGet, "", "bob").Body.String(), token)
+
+	assert.Equal(t, request(http.MethodPost, token, "alice").Code, http.StatusCreated)
+	assert.Equal(t, request(http.MethodPost, token, "bob").Code, http.StatusForbidden)
+
+	res = request(http.MethodGet, "", "")
+	cookie := res.Result().Cookies()[0]
+	assert.True(t, cookie.HttpOnly)
+	assert.NotEqual(t, cookie.Value, res.Body.String())
+}
+
+func TestMemoryCSRFStore(t *testing.T) {
+	store := NewMemoryCSRFStore()
+	ctx := context.Background()
+	assert.NoError(t, store.SetToken(ctx, "a", "token", time.Hour))
+	assert.NoError(t, store.SetToken(ctx, "b", "expired", time.Nanosecond))
+	time.Sleep(time.Millisecond)
+
+	token, err := store.Token(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, token, "token")
+	token, err = store.Token(ctx, "b")
+	assert.NoError(t, err)
+	assert.Empty(t, token)
+
+	store.lastSweep = time.Now().Add(-time.Hour)
+	assert.NoError(t, store.SetToken(ctx, "c", "token", time.Hour))
+	assert.Equal(t, len(store.tokens), 2)
+}
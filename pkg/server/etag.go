@@ -0,0 +1,269 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultETagMaxBodySize is the size of the largest response hashed by the
+// ETag middleware when ETagConfig.MaxBodySize is not positive.
+const DefaultETagMaxBodySize = 1 << 20
+
+// ErrPreconditionFailed is returned by Context.CheckPreconditions when the
+// client holds a stale version of the resource.
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// ETagConfig configures the ETag middleware.
+type ETagConfig struct {
+	// Weak generates weak ETags, which tell the responses are equivalent
+	// rather than byte for byte identical. Use them when responses can be
+	// re-encoded on their way, for instance by the Compress middleware.
+	Weak bool
+	// MaxBodySize is the size of the largest response buffered to be
+	// hashed, bigger ones get no ETag. Defaults to DefaultETagMaxBodySize.
+	MaxBodySize int
+}
+
+// ETag adds an ETag, a hash of the body, to the successful responses of
+// GET and HEAD requests, unless the handler set one, and answers
+// 304 Not Modified when it matches the If-None-Match header or, without
+// that header, when the Last-Modified header set by the handler is not
+// after If-Modified-Since. Responses are buffered to be hashed, except the
+// streamed ones and the ones over ETagConfig.MaxBodySize, which get no
+// ETag, and the ones whose handler set an ETag, which are passed through.
+//
+//	server.Use(i9.ETag())
+func ETag(options ...ETagConfig) HandlerWithContext {
+	var config ETagConfig
+	if len(options) > 0 {
+		config = options[0]
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = DefaultETagMaxBodySize
+	}
+	return func(c *Context) error {
+		r := c.Request.HTTP()
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			return nil
+		}
+		w := &etagWriter{
+			wrappedWriter: wrappedWriter{ResponseWriter: c.Response.HTTP()},
+			r:             r,
+			maxBodySize:   config.MaxBodySize,
+		}
+		w.beforeFlush, w.beforeHijack = w.release, w.bypass
+		c.Response.ChangeResponseWriter(w)
+		c.Next()
+		if w.passthrough || w.status == 0 && w.buf.Len() == 0 {
+			return nil
+		}
+		etag := hashETag(w.buf.Bytes(), config.Weak)
+		w.Header().Set("ETag", etag)
+		if notModified(r, etag, w.Header().Get("Last-Modified")) {
+			w.writeNotModified()
+			return nil
+		}
+		w.flushBuffer()
+		return nil
+	}
+}
+
+func hashETag(body []byte, weak bool) string {
+	hash := fnv.New64a()
+	hash.Write(body)
+	etag := fmt.Sprintf(`"%x-%x"`, len(body), hash.Sum64())
+	if weak {
+		etag = "W/" + etag
+	}
+	return etag
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since without it,
+// for a GET or HEAD request.
+func notModified(r *http.Request, etag, lastModified string) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etag != "" && etagMatches(inm, etag, false)
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	return err == nil && !modified.After(since)
+}
+
+// etagMatches reports whether etag is in the list of entity tags of an
+// If-Match or If-None-Match header, "*" matching any. The strong
+// comparison requires both tags to be strong.
+func etagMatches(header, etag string, strong bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	opaque, weak := strings.CutPrefix(etag, "W/")
+	for header != "" {
+		header = strings.TrimLeft(header, " \t,")
+		candidate, candidateWeak := strings.CutPrefix(header, "W/")
+		if !strings.HasPrefix(candidate, `"`) {
+			return false
+		}
+		end := strings.IndexByte(candidate[1:], '"')
+		if end < 0 {
+			return false
+		}
+		tag := candidate[:end+2]
+		header = candidate[end+2:]
+		if tag == opaque && (!strong || !weak && !candidateWeak) {
+			return true
+		}
+	}
+	return false
+}
+
+// etagWriter buffers the successful responses without an ETag to hash
+// them. A flush, a hijack or a body over maxBodySize switches it to pass
+// the response through.
+type etagWriter struct {
+	wrappedWriter
+	r           *http.Request
+	maxBodySize int
+	status      int
+	buf         bytes.Buffer
+	passthrough bool
+	discard     bool
+}
+
+func (w *etagWriter) WriteHeader(code int) {
+	switch {
+	case w.passthrough, code < http.StatusOK:
+		w.ResponseWriter.WriteHeader(code)
+	case w.status == 0:
+		w.status = code
+		w.start()
+	}
+}
+
+// start passes the response through when it is not worth buffering: when
+// it failed, carries the ETag of the handler, answered right away, or
+// announces a body over maxBodySize.
+func (w *etagWriter) start() {
+	h := w.Header()
+	size, _ := strconv.Atoi(h.Get("Content-Length"))
+	switch {
+	case w.status != http.StatusOK, size > w.maxBodySize:
+		w.flushBuffer()
+	case h.Get("ETag") != "":
+		if notModified(w.r, h.Get("ETag"), h.Get("Last-Modified")) {
+			w.writeNotModified()
+		} else {
+			w.flushBuffer()
+		}
+	}
+}
+
+func (w *etagWriter) Write(b []byte) (int, error) {
+	if !w.passthrough && w.status == 0 {
+		w.status = http.StatusOK
+		w.start()
+	}
+	switch {
+	case w.discard:
+		return len(b), nil
+	case w.passthrough:
+		return w.ResponseWriter.Write(b)
+	case w.buf.Len()+len(b) > w.maxBodySize:
+		w.flushBuffer()
+		return w.ResponseWriter.Write(b)
+	}
+	return w.buf.Write(b)
+}
+
+// writeNotModified answers 304 Not Modified and discards the body.
+func (w *etagWriter) writeNotModified() {
+	h := w.Header()
+	for _, name := range []string{"Content-Type", "Content-Length", "Content-Encoding"} {
+		h.Del(name)
+	}
+	w.passthrough, w.discard = true, true
+	w.buf.Reset()
+	w.ResponseWriter.WriteHeader(http.StatusNotModified)
+}
+
+// flushBuffer writes the recorded status and body and passes the rest through.
+func (w *etagWriter) flushBuffer() {
+	w.passthrough = true
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.buf.Len() > 0 {
+		w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+	}
+}
+
+// release writes the buffered response on a flush, which streamed
+// responses are not tagged for.
+func (w *etagWriter) release() {
+	if !w.passthrough {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.flushBuffer()
+	}
+}
+
+func (w *etagWriter) bypass() {
+	w.passthrough = true
+}
+
+// CheckPreconditions evaluates the If-Match, If-Unmodified-Since and
+// If-None-Match headers of a request modifying a resource against its
+// current version, for optimistic concurrency control. The version is an
+// entity tag, quoted or not, empty when the resource does not exist, and
+// lastModified is the zero time when unknown. It returns an *Error with
+// status 412 Precondition Failed when the client holds a stale version.
+//
+//	server.Put("/articles/:id", func(c *i9.Context) error {
+//		article := articles.Find(c.Params("id"))
+//		if err := c.CheckPreconditions(article.Version, article.UpdatedAt); err != nil {
+//			return err
+//		}
+//		// update the article
+//	})
+func (c *Context) CheckPreconditions(version string, lastModified time.Time) error {
+	r := c.Request.HTTP()
+	etag := quoteETag(version)
+	failed := &Error{StatusCode: http.StatusPreconditionFailed, Err: ErrPreconditionFailed}
+
+	if im := r.Header.Get("If-Match"); im != "" {
+		if etag == "" || !etagMatches(im, etag, true) {
+			return failed
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err == nil && !lastModified.IsZero() {
+		if lastModified.Truncate(time.Second).After(since) {
+			return failed
+		}
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etag != "" && etagMatches(inm, etag, false) {
+		return failed
+	}
+	return nil
+}
+
+// SetETag sets the ETag header from a resource version, quoted unless it
+// already is, so the ETag middleware and CheckPreconditions use it.
+func (c *Context) SetETag(version string) {
+	c.Response.SetHeader("ETag", quoteETag(version))
+}
+
+func quoteETag(version string) string {
+	if version == "" || strings.HasPrefix(version, `"`) || strings.HasPrefix(version, `W/"`) {
+		return version
+	}
+	return `"` + version + `"`
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/i9si-sistemas/assert"
+)
+
+func TestETag(t *testing.T) {
+	modified := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	server := New(0)
+	server.Use(ETag())
+	server.Get("/users", func(c *Context) error {
+		return c.JSON(JSON{"name": "nine"})
+	})
+	server.Get("/versioned", func(c *Context) error {
+		c.SetETag("v7")
+		c.Response.SetHeader("Last-Modified", modified.Format(http.TimeFormat))
+		return c.SendString("article")
+	})
+	server.Get("/missing", func(c *Context) error {
+		return c.Status(http.StatusNotFound).SendString("not found")
+	})
+	server.Post("/users", func(c *Context) error {
+		return c.SendString("created")
+	})
+	request := func(method, path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		return server.Test().Request(req)
+	}
+
+	res := request(http.MethodGet, "/users", nil)
+	assert.Equal(t, res.Code, http.StatusOK)
+	etag := res.Header().Get("ETag")
+	assert.True(t, strings.HasPrefix(etag, `"`))
+	assert.Equal(t, res.Body.String(), `{"name":"nine"}`+"\n")
+
+	res = request(http.MethodGet, "/users", http.Header{"If-None-Match": {`"other", W/` + etag}})
+	assert.Equal(t, res.Code, http.StatusNotModified)
+	assert.Equal(t, res.Header().Get("ETag"), etag)
+	assert.Empty(t, res.Header().Get("Content-Type"))
+	assert.Empty(t, res.Body.String())
+
+	res = request(http.MethodHead, "/users", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, res.Code, http.StatusNotModified)
+	res = request(http.MethodGet, "/users", http.Header{"If-None-Match": {`"stale"`}})
+	assert.Equal(t, res.Code, http.StatusOK)
+
+	res = request(http.MethodGet, "/versioned", http.Header{"If-None-Match": {`"v7"`}})
+	assert.Equal(t, res.Code, http.StatusNotModified)
+	res = request(http.MethodGet, "/versioned", http.Header{"If-Modified-Since": {modified.Format(http.TimeFormat)}})
+	assert.Equal(t, res.Code, http.StatusNotModified)
+	res = request(http.MethodGet, "/versioned", http.Header{
+		"If-Modified-Since": {modified.Add(-time.Hour).Format(http.TimeFormat)},
+	})
+	assert.Equal(t, res.Code, http.StatusOK)
+	assert.Equal(t, res.Body.String(), "article")
+	res = request(http.MethodGet, "/versioned", http.Header{
+		"If-None-Match":     {`"v6"`},
+		"If-Modified-Since": {modified.Format(http.TimeFormat)},
+	})
+	assert.Equal(t, res.Code, http.StatusOK)
+
+	res = request(http.MethodGet, "/missing", http.Header{"If-None-Match": {"*"}})
+	assert.Equal(t, res.Code, http.StatusNotFound)
+	assert.Empty(t, res.Header().Get("ETag"))
+	res = request(http.MethodPost, "/users", nil)
+	assert.Empty(t, res.Header().Get("ETag"))
+
+	weak := New(0)
+	weak.Use(ETag(ETagConfig{Weak: true}))
+	weak.Get("/", func(c *Context) error {
+		return c.SendString("ok")
+	})
+	res = weak.Test().Request(httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.True(t, strings.HasPrefix(res.Header().Get("ETag"), `W/"`))
+}
+
+func TestETagStreaming(t *testing.T) {
+	server := New(0)
+	server.Use(ETag())
+	server.Get("/events", func(c *Context) error {
+		return c.SSE(func(stream *EventStream) error {
+			return stream.Send("", "", "hello")
+		})
+	})
+	res := server.Test().Request(httptest.NewRequest(http.MethodGet, "/events", nil))
+	assert.Empty(t, res.Header().Get("ETag"))
+	assert.True(t, strings.Contains(res.Body.String(), "data: hello"))
+}
+
+func TestETagMaxBodySize(t *testing.T) {
+	server := New(0)
+	server.Use(ETag(ETagConfig{MaxBodySize: 8}))
+	server.Get("/small", func(c *Context) error {
+		return c.SendString("small")
+	})
+	server.Get("/large", func(c *Context) error {
+		return c.SendString(strings.Repeat("large ", 10))
+	})
+	request := func(path string) *httptest.ResponseRecorder {
+		return server.Test().Request(httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	assert.NotEmpty(t, request("/small").Header().Get("ETag"))
+	res := request("/large")
+	assert.Empty(t, res.Header().Get("ETag"))
+	assert.Equal(t, res.Body.String(), strings.Repeat("large ", 10))
+}
+
+func TestETagCompress(t *testing.T) {
+	server := New(0)
+	server.Use(Compress(CompressConfig{MinSize: -1}), ETag())
+	server.Get("/", func(c *Context) error {
+		return c.SendString(strings.Repeat("compressible ", 100))
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	res := server.Test().Request(req)
+	etag := res.Header().Get("ETag")
+	assert.True(t, strings.HasPrefix(etag, `W/"`))
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("If-None-Match", etag)
+	assert.Equal(t, server.Test().Request(req).Code, http.StatusNotModified)
+}
+
+func TestCheckPreconditions(t *testing.T) {
+	modified := time.Date(2025, 3, 1, 12, 0, 0, 500, time.UTC)
+	check := func(version string, header http.Header) error {
+		req := httptest.NewRequest(http.MethodPut, "/articles/1", nil)
+		req.Header = header
+		c := NewContext(req.Context(), req, httptest.NewRecorder())
+		return c.CheckPreconditions(version, modified)
+	}
+	failed := func(err error) bool {
+		srvErr, ok := err.(*Error)
+		return ok && srvErr.StatusCode == http.StatusPreconditionFailed && srvErr.Err == ErrPreconditionFailed
+	}
+
+	assert.NoError(t, check("v2", http.Header{}))
+	assert.NoError(t, check("v2", http.Header{"If-Match": {`"v1", "v2"`}}))
+	assert.NoError(t, check(`"v2"`, http.Header{"If-Match": {"*"}}))
+	assert.True(t, failed(check("v2", http.Header{"If-Match": {`"v1"`}})))
+	assert.True(t, failed(check("v2", http.Header{"If-Match": {`W/"v2"`}})))
+	assert.True(t, failed(check("", http.Header{"If-Match": {"*"}})))
+
+	assert.NoError(t, check("v2", http.Header{"If-Unmodified-Since": {modified.Format(http.TimeFormat)}}))
+	assert.True(t, failed(check("v2", http.Header{
+		"If-Unmodified-Since": {modified.Add(-time.Minute).Format(http.TimeFormat)},
+	})))
+	assert.NoError(t, check("v2", http.Header{
+		"If-Match":            {`"v2"`},
+		"If-Unmodified-Since": {modified.Add(-time.Minute).Format(http.TimeFormat)},
+	}))
+
+	assert.NoError(t, check("", http.Header{"If-None-Match": {"*"}}))
+	assert.True(t, failed(check("v2", http.Header{"If-None-Match": {"*"}})))
+}
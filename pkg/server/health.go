@@ -0,0 +1,195 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Defaults of HealthConfig.
+const (
+	DefaultLivenessPath       = "/livez"
+	DefaultReadinessPath      = "/readyz"
+	DefaultHealthCheckTimeout = 5 * time.Second
+	DefaultHealthCacheTTL     = time.Second
+)
+
+// Health statuses reported by the health endpoints.
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+)
+
+// HealthCheck is a named check of a dependency, such as a database ping.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+	// Timeout bounds the check, whose context is canceled once it
+	// expires. Defaults to HealthConfig.Timeout.
+	Timeout time.Duration
+}
+
+// HealthConfig configures Server.Health.
+type HealthConfig struct {
+	// LivenessPath defaults to DefaultLivenessPath.
+	LivenessPath string
+	// ReadinessPath defaults to DefaultReadinessPath.
+	ReadinessPath string
+	// Liveness checks tell whether the process works at all and must be
+	// restarted otherwise. Keep them cheap and local.
+	Liveness []HealthCheck
+	// Readiness checks tell whether the server can handle traffic,
+	// such as whether its database is reachable.
+	Readiness []HealthCheck
+	// Timeout is the default timeout of the checks.
+	// Defaults to DefaultHealthCheckTimeout.
+	Timeout time.Duration
+	// CacheTTL is how long the result of a check is reused, so frequent
+	// probes do not overload the dependencies. Defaults to
+	// DefaultHealthCacheTTL, a negative value disables the cache.
+	CacheTTL time.Duration
+}
+
+// HealthReport is the JSON body of the health endpoints.
+type HealthReport struct {
+	Status string                       `json:"status"`
+	Error  string                       `json:"error,omitempty"`
+	Checks map[string]HealthCheckResult `json:"checks,omitempty"`
+}
+
+// HealthCheckResult is the result of a check in a HealthReport.
+type HealthCheckResult struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Duration  float64   `json:"duration_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Health registers the liveness and readiness endpoints. They run their
+// checks concurrently and respond 200 OK when every check passes and
+// 503 Service Unavailable otherwise, with a HealthReport:
+//
+//	{"status":"fail","checks":{"postgres":{"status":"fail","error":"context deadline exceeded",...}}}
+//
+// Readiness also fails while the server starts, until its OnStartup hooks
+// ran and its listener is bound, and while it drains during a graceful
+// shutdown, so load balancers stop sending it traffic.
+//
+//	server.Health(i9.HealthConfig{
+//		Readiness: []i9.HealthCheck{{Name: "postgres", Check: db.PingContext}},
+//	})
+func (s *Server) Health(options ...HealthConfig) error {
+	var config HealthConfig
+	if len(options) > 0 {
+		config = options[0]
+	}
+	timeout := cmp.Or(config.Timeout, DefaultHealthCheckTimeout)
+	ttl := cmp.Or(config.CacheTTL, DefaultHealthCacheTTL)
+
+	liveness := newHealthChecks(config.Liveness, timeout, ttl)
+	if err := s.Get(cmp.Or(config.LivenessPath, DefaultLivenessPath), func(c *Context) error {
+		return liveness.respond(c, HealthReport{})
+	}); err != nil {
+		return err
+	}
+	readiness := newHealthChecks(config.Readiness, timeout, ttl)
+	return s.Get(cmp.Or(config.ReadinessPath, DefaultReadinessPath), func(c *Context) error {
+		var report HealthReport
+		switch {
+		case s.draining.Load():
+			report.Error = "server is shutting down"
+		case !s.IsReady():
+			report.Error = "server is starting"
+		}
+		return readiness.respond(c, report)
+	})
+}
+
+type healthChecks []*cachedHealthCheck
+
+type cachedHealthCheck struct {
+	HealthCheck
+	ttl time.Duration
+
+	mu     sync.Mutex
+	result HealthCheckResult
+}
+
+func newHealthChecks(checks []HealthCheck, timeout, ttl time.Duration) healthChecks {
+	cached := make(healthChecks, len(checks))
+	for i, check := range checks {
+		check.Timeout = cmp.Or(check.Timeout, timeout)
+		cached[i] = &cachedHealthCheck{HealthCheck: check, ttl: ttl}
+	}
+	return cached
+}
+
+// respond runs the checks and writes the report. A report which already
+// holds an error fails without running them.
+func (checks healthChecks) respond(c *Context, report HealthReport) error {
+	report.Status = HealthStatusOK
+	if report.Error != "" {
+		report.Status = HealthStatusFail
+	} else if len(checks) > 0 {
+		results := make([]HealthCheckResult, len(checks))
+		var wg sync.WaitGroup
+		for i, check := range checks {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i] = check.run(c.Request.Context())
+			}()
+		}
+		wg.Wait()
+		report.Checks = make(map[string]HealthCheckResult, len(checks))
+		for i, result := range results {
+			report.Checks[checks[i].Name] = result
+			if result.Status != HealthStatusOK {
+				report.Status = HealthStatusFail
+			}
+		}
+	}
+	c.Response.SetHeader("Cache-Control", "no-store")
+	status := http.StatusOK
+	if report.Status != HealthStatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	return c.Status(status).JSON(report)
+}
+
+// run returns the cached result while it is fresh. Concurrent probes wait
+// for the running check instead of starting their own.
+func (check *cachedHealthCheck) run(ctx context.Context) HealthCheckResult {
+	check.mu.Lock()
+	defer check.mu.Unlock()
+	if !check.result.CheckedAt.IsZero() && time.Since(check.result.CheckedAt) < check.ttl {
+		return check.result
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), check.Timeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check.Check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := HealthCheckResult{
+		Status:    HealthStatusOK,
+		Duration:  float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: start,
+	}
+	if err != nil {
+		result.Status, result.Error = HealthStatusFail, err.Error()
+	}
+	check.result = result
+	return result
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/i9si-sistemas/assert"
+	"github.com/i9si-sistemas/nine/internal/json"
+)
+
+func healthReport(t *testing.T, server *Server, path string) (int, HealthReport) {
+	t.Helper()
+	res := server.Test().Request(httptest.NewRequest(http.MethodGet, path, nil))
+	assert.Equal(t, res.Header().Get("Cache-Control"), "no-store")
+	var report HealthReport
+	assert.NoError(t, json.Decode(res.Body.Bytes(), &report))
+	return res.Code, report
+}
+
+func TestHealth(t *testing.T) {
+	var dbCalls atomic.Int32
+	dbErr := errors.New("connection refused")
+	var failing atomic.Bool
+	server := New(0)
+	err := server.Health(HealthConfig{
+		Liveness: []HealthCheck{{Name: "goroutines", Check: func(context.Context) error { return nil }}},
+		Readiness: []HealthCheck{
+			{Name: "db", Check: func(context.Context) error {
+				dbCalls.Add(1)
+				if failing.Load() {
+					return dbErr
+				}
+				return nil
+			}},
+			{Name: "slow", Timeout: 20 * time.Millisecond, Check: func(ctx context.Context) error {
+				time.Sleep(time.Second)
+				return nil
+			}},
+		},
+		CacheTTL: -1,
+	})
+	assert.NoError(t, err)
+
+	var report HealthReport
+	res := httptest.NewRecorder()
+	server.Handler().ServeHTTP(res, httptest.NewRequest(http.MethodGet, DefaultReadinessPath, nil))
+	assert.Equal(t, res.Code, http.StatusServiceUnavailable)
+	assert.NoError(t, json.Decode(res.Body.Bytes(), &report))
+	assert.Equal(t, report.Error, "server is starting")
+
+	code, report := healthReport(t, server, DefaultLivenessPath)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, report.Status, HealthStatusOK)
+	assert.Equal(t, report.Checks["goroutines"].Status, HealthStatusOK)
+
+	start := time.Now()
+	code, report = healthReport(t, server, DefaultReadinessPath)
+	assert.True(t, time.Since(start) < 500*time.Millisecond)
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+	assert.Empty(t, report.Error)
+	assert.Equal(t, report.Checks["db"].Status, HealthStatusOK)
+	assert.Equal(t, report.Checks["slow"].Status, HealthStatusFail)
+	assert.Equal(t, report.Checks["slow"].Error, context.DeadlineExceeded.Error())
+
+	failing.Store(true)
+	_, report = healthReport(t, server, DefaultReadinessPath)
+	assert.Equal(t, report.Checks["db"].Error, dbErr.Error())
+	assert.Equal(t, dbCalls.Load(), int32(2))
+
+	server.draining.Store(true)
+	code, report = healthReport(t, server, DefaultReadinessPath)
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+	assert.Equal(t, report.Error, "server is shutting down")
+	code, _ = healthReport(t, server, DefaultLivenessPath)
+	assert.Equal(t, code, http.StatusOK)
+}
+
+func TestHealthListenFn(t *testing.T) {
+	var server *Server
+	server = New(0, ServerOpts{ListenFn: func() error {
+		server.MarkReady()
+		return nil
+	}})
+	assert.False(t, server.IsReady())
+	assert.NoError(t, server.Listen())
+	assert.True(t, server.IsReady())
+}
+
+func TestHealthCache(t *testing.T) {
+	var calls atomic.Int32
+	server := New(0)
+	server.Health(HealthConfig{
+		LivenessPath: "/healthz",
+		Liveness: []HealthCheck{{Name: "cache", Check: func(context.Context) error {
+			calls.Add(1)
+			return nil
+		}}},
+		CacheTTL: time.Minute,
+	})
+	for range 3 {
+		code, report := healthReport(t, server, "/healthz")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, report.Checks["cache"].Status, HealthStatusOK)
+	}
+	assert.Equal(t, calls.Load(), int32(1))
+}
+
+func TestReadinessLifecycle(t *testing.T) {
+	server := New("", ServerOpts{DrainPeriod: 200 * time.Millisecond})
+	assert.NoError(t, server.Health())
+	startServer(t, server, server.Listen)
+	url := "http://" + server.Addr().String() + DefaultReadinessPath
+
+	res, err := http.Get(url)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		server.drain(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	res, err = http.Get(url)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, res.StatusCode, http.StatusServiceUnavailable)
+	<-done
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultHSTSMaxAge is the max-age of HSTS headers when HSTSConfig.MaxAge is zero.
+const DefaultHSTSMaxAge = 2 * 365 * 24 * time.Hour
+
+// HTTPSRedirectConfig configures the plain HTTP server started along
+// with ListenTLS to redirect clients to HTTPS.
+type HTTPSRedirectConfig struct {
+	// Addr is the address of the redirect server. Defaults to ":80".
+	Addr string
+	// StatusCode is the redirect status. Defaults to 308 Permanent Redirect,
+	// which keeps the request method.
+	StatusCode int
+}
+
+// startRedirect binds the HTTPS redirect server, which is stopped by Shutdown.
+func (s *Server) startRedirect() error {
+	config := s.httpsRedirect
+	ln, err := net.Listen("tcp", cmp.Or(config.Addr, ":80"))
+	if err != nil {
+		return fmt.Errorf("https redirect: %w", err)
+	}
+	srv := &http.Server{
+		Handler:           s.redirectHandler(cmp.Or(config.StatusCode, http.StatusPermanentRedirect)),
+		ReadHeaderTimeout: s.httpServer.ReadHeaderTimeout,
+		IdleTimeout:       s.httpServer.IdleTimeout,
+		ErrorLog:          s.httpServer.ErrorLog,
+	}
+	s.mu.Lock()
+	s.redirectServer, s.redirectListener = srv, ln
+	s.mu.Unlock()
+	go srv.Serve(ln)
+	return nil
+}
+
+func (s *Server) redirectHandler(code int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := stripPort(r.Host)
+		if host == "" {
+			http.Error(w, "missing host", http.StatusBadRequest)
+			return
+		}
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		if port := s.Port(); port != "" && port != "443" {
+			host += ":" + port
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), code)
+	})
+}
+
+// shutdownRedirect stops the HTTPS redirect server, when it runs.
+func (s *Server) shutdownRedirect(ctx context.Context) error {
+	srv := s.takeRedirect()
+	if srv == nil {
+		return nil
+	}
+	if err := srv.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// closeRedirect stops the HTTPS redirect server right away, when ListenTLS fails.
+func (s *Server) closeRedirect() {
+	if srv := s.takeRedirect(); srv != nil {
+		srv.Close()
+	}
+}
+
+// takeRedirect detaches the HTTPS redirect server and closes its listener,
+// which its Serve goroutine may not be tracking yet.
+func (s *Server) takeRedirect() *http.Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	srv, ln := s.redirectServer, s.redirectListener
+	s.redirectServer, s.redirectListener = nil, nil
+	if ln != nil {
+		ln.Close()
+	}
+	return srv
+}
+
+// HSTSConfig configures the Strict-Transport-Security header.
+type HSTSConfig struct {
+	// MaxAge is how long browsers remember to only use HTTPS.
+	// Defaults to DefaultHSTSMaxAge.
+	MaxAge time.Duration
+	// IncludeSubDomains applies the policy to every subdomain.
+	IncludeSubDomains bool
+	// Preload allows the domain in the browsers preload lists, which
+	// also requires IncludeSubDomains and a MaxAge of at least a year.
+	Preload bool
+}
+
+// HSTS sets the Strict-Transport-Security header on requests received over
+// TLS, directly or through a trusted proxy. Browsers ignore it over plain
+// HTTP, where it is never sent.
+//
+//	server.Use(i9.HSTS(i9.HSTSConfig{IncludeSubDomains: true}))
+func HSTS(options ...HSTSConfig) HandlerWithContext {
+	var config HSTSConfig
+	if len(options) > 0 {
+		config = options[0]
+	}
+	value := fmt.Sprintf("max-age=%d", int64(cmp.Or(config.MaxAge, DefaultHSTSMaxAge).Seconds()))
+	if config.IncludeSubDomains {
+		value += "; includeSubDomains"
+	}
+	if config.Preload {
+		value += "; preload"
+	}
+	return func(c *Context) error {
+		if c.Protocol() == "https" {
+			c.Response.SetHeader("Strict-Transport-Security", value)
+		}
+		return nil
+	}
+}
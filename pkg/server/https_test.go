@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/i9si-sistemas/assert"
+)
+
+func TestHTTPSRedirect(t *testing.T) {
+	server := New("", ServerOpts{
+		HTTPSRedirect: &HTTPSRedirectConfig{Addr: "127.0.0.1:0"},
+		SelfSignedTLS: true,
+	})
+	server.Get("/orders", func(c *Context) error {
+		return c.SendString("secure")
+	})
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.ListenTLS("", "") }()
+	<-server.Ready()
+
+	server.mu.RLock()
+	redirectAddr := server.redirectListener.Addr().String()
+	server.mu.RUnlock()
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	res, err := client.Post("http://"+redirectAddr+"/orders?page=2", "text/plain", nil)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, res.StatusCode, http.StatusPermanentRedirect)
+	assert.Equal(t, res.Header.Get("Location"), "https://127.0.0.1:"+server.Port()+"/orders?page=2")
+
+	tlsClient := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+	assert.Equal(t, get(t, tlsClient, res.Header.Get("Location")), "secure")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, server.Shutdown(ctx))
+	assert.NoError(t, <-errCh)
+	_, err = client.Get("http://" + redirectAddr + "/")
+	assert.NotNil(t, err)
+}
+
+func TestHTTPSRedirectServeError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	redirectAddr := ln.Addr().String()
+	ln.Close()
+
+	server := New("", ServerOpts{
+		HTTPSRedirect: &HTTPSRedirectConfig{Addr: redirectAddr},
+	})
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+	assert.Error(t, server.ListenTLS(missing, missing))
+	server.mu.RLock()
+	assert.True(t, server.redirectServer == nil)
+	server.mu.RUnlock()
+	ln, err = net.Listen("tcp", redirectAddr)
+	assert.NoError(t, err)
+	ln.Close()
+}
+
+func TestHTTPSRedirectHandler(t *testing.T) {
+	server := New(443)
+	handler := server.redirectHandler(http.StatusMovedPermanently)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/a?b=c", nil)
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusMovedPermanently)
+	assert.Equal(t, res.Header().Get("Location"), "https://example.com/a?b=c")
+
+	req = httptest.NewRequest(http.MethodGet, "http://[::1]:80/", nil)
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	assert.Equal(t, res.Header().Get("Location"), "https://[::1]/")
+}
+
+func TestHSTS(t *testing.T) {
+	server := New(0, ServerOpts{
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+	})
+	server.Use(HSTS())
+	server.Get("/", func(c *Context) error {
+		return c.SendString("ok")
+	})
+	request := func(configure func(r *http.Request)) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		configure(req)
+		return server.Test().Request(req).Header().Get("Strict-Transport-Security")
+	}
+
+	assert.Empty(t, request(func(r *http.Request) {}))
+	assert.Equal(t, request(func(r *http.Request) { r.TLS = &tls.ConnectionState{} }), "max-age=63072000")
+	assert.Equal(t, request(func(r *http.Request) {
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("X-Forwarded-Proto", "https")
+	}), "max-age=63072000")
+	assert.Empty(t, request(func(r *http.Request) {
+		r.RemoteAddr = "203.0.113.1:1234"
+		r.Header.Set("X-Forwarded-Proto", "https")
+	}))
+
+	value := HSTSConfig{MaxAge: 365 * 24 * time.Hour, IncludeSubDomains: true, Preload: true}
+	server = New(0)
+	server.Use(HSTS(value))
+	server.Get("/", func(c *Context) error {
+		return c.SendString("ok")
+	})
+	assert.Equal(t, request(func(r *http.Request) { r.TLS = &tls.ConnectionState{} }),
+		"max-age=31536000; includeSubDomains; preload")
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Defaults of the lifecycle settings of ServerOpts.
+const (
+	DefaultShutdownTimeout = 30 * time.Second
+	DefaultHookTimeout     = 10 * time.Second
+)
+
+// Hook is a function run when the server starts or shuts down.
+// Its context is canceled when the hook timeout expires.
+type Hook func(ctx context.Context) error
+
+// OnStartup registers hooks run in order before the server binds its
+// listener, for instance to open database connections. The first failing
+// hook aborts the startup and its error is returned by Listen.
+func (s *Server) OnStartup(hooks ...Hook) {
+	s.startupHooks = append(s.startupHooks, hooks...)
+}
+
+// OnShutdown registers hooks run in order once the server has stopped
+// accepting requests, for instance to flush buffers or close connections.
+// Every hook runs, even after a failure, and their errors are joined.
+func (s *Server) OnShutdown(hooks ...Hook) {
+	s.shutdownHooks = append(s.shutdownHooks, hooks...)
+}
+
+// MarkReady reports the server as ready. Listen and Serve call it once they
+// accept connections, servers started through ServerOpts.ListenFn call it
+// themselves.
+func (s *Server) MarkReady() {
+	s.draining.Store(false)
+	s.markReady()
+}
+
+func (s *Server) markReady() {
+	s.readyOnce.Do(func() { close(s.ready) })
+}
+
+// IsReady reports whether the server is accepting requests and not draining.
+func (s *Server) IsReady() bool {
+	select {
+	case <-s.ready:
+		return !s.draining.Load()
+	default:
+		return false
+	}
+}
+
+// Run starts the server and blocks until ctx is canceled or the process
+// receives SIGINT or SIGTERM. It then shuts the server down gracefully:
+// readiness reports not ready during ServerOpts.DrainPeriod so load
+// balancers stop sending traffic, in-flight requests are given
+// ServerOpts.ShutdownTimeout to complete and the OnShutdown hooks run.
+// The OnShutdown hooks also run when the address cannot be bound, since
+// the OnStartup hooks ran before.
+//
+//	server := nine.NewServer(os.Getenv("PORT"))
+//	server.OnShutdown(func(ctx context.Context) error {
+//		return db.Close()
+//	})
+//	if err := server.Run(context.Background()); err != nil {
+//		log.Fatal(err)
+//	}
+func (s *Server) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Listen()
+	}()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return err
+		}
+		return s.runShutdownHooks(context.Background())
+	case <-ctx.Done():
+	}
+	// A second signal terminates the process immediately.
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	err := s.Shutdown(shutdownCtx)
+	return errors.Join(err, <-errCh)
+}
+
+// drain marks the server as not ready and waits for the drain period.
+func (s *Server) drain(ctx context.Context) {
+	if s.draining.Swap(true) || s.drainPeriod <= 0 || s.Addr() == nil {
+		return
+	}
+	timer := time.NewTimer(s.drainPeriod)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+func (s *Server) runStartupHooks() error {
+	for i, hook := range s.startupHooks {
+		if err := s.runHook(context.Background(), hook); err != nil {
+			return fmt.Errorf("startup hook %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (s *Server) runShutdownHooks(ctx context.Context) error {
+	var errs []error
+	s.shutdownOnce.Do(func() {
+		for i, hook := range s.shutdownHooks {
+			if err := s.runHook(ctx, hook); err != nil {
+				errs = append(errs, fmt.Errorf("shutdown hook %d: %w", i, err))
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// runHook runs hook with the hook timeout, returning when it expires
+// even if the hook ignores its context. The hook gets the values of ctx
+// but not its deadline, which the shutdown may have used up already.
+func (s *Server) runHook(ctx context.Context, hook Hook) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.hookTimeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- hook(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
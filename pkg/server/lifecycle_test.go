@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/i9si-sistemas/assert"
+)
+
+func TestRun(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	record := func(name string) Hook {
+		return func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, name)
+			return nil
+		}
+	}
+	server := New("", ServerOpts{DrainPeriod: 100 * time.Millisecond})
+	server.Get("/", func(c *Context) error {
+		return c.SendString("ok")
+	})
+	server.OnStartup(record("startup 1"), record("startup 2"))
+	server.OnShutdown(record("shutdown 1"), record("shutdown 2"))
+	assert.False(t, server.IsReady())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.Run(ctx) }()
+	<-server.Ready()
+	assert.True(t, server.IsReady())
+	url := "http://127.0.0.1:" + server.Port() + "/"
+
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	assert.False(t, server.IsReady())
+	assert.Equal(t, get(t, http.DefaultClient, url), "ok")
+
+	select {
+	case err := <-errCh:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return")
+	}
+	assert.Equal(t, calls, []string{"startup 1", "startup 2", "shutdown 1", "shutdown 2"})
+	_, err := http.Get(url)
+	assert.NotNil(t, err)
+}
+
+func TestHooks(t *testing.T) {
+	t.Run("StartupFailure", func(t *testing.T) {
+		errStartup := errors.New("database unavailable")
+		var ran bool
+		server := New("")
+		server.OnStartup(
+			func(context.Context) error { return errStartup },
+			func(context.Context) error { ran = true; return nil },
+		)
+		err := server.Listen()
+		assert.True(t, errors.Is(err, errStartup))
+		assert.False(t, ran)
+		assert.Nil(t, server.Addr())
+	})
+
+	t.Run("BindFailure", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		defer ln.Close()
+		server := New(ln.Addr().(*net.TCPAddr).Port)
+		var started, closed bool
+		server.OnStartup(func(context.Context) error { started = true; return nil })
+		server.OnShutdown(func(context.Context) error { closed = true; return nil })
+		err = server.Run(context.Background())
+		assert.True(t, err != nil)
+		assert.True(t, started)
+		assert.True(t, closed)
+	})
+
+	t.Run("ShutdownErrors", func(t *testing.T) {
+		errFirst, errLast := errors.New("first"), errors.New("last")
+		server := New("", ServerOpts{HookTimeout: 50 * time.Millisecond})
+		var ran bool
+		server.OnShutdown(
+			func(context.Context) error { return errFirst },
+			func(ctx context.Context) error {
+				<-ctx.Done()
+				return nil
+			},
+			func(context.Context) error { select {} },
+			func(context.Context) error { ran = true; return errLast },
+		)
+		err := server.Shutdown(context.Background())
+		assert.True(t, errors.Is(err, errFirst))
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		assert.True(t, errors.Is(err, errLast))
+		assert.True(t, ran)
+
+		assert.NoError(t, server.Shutdown(context.Background()))
+	})
+
+	t.Run("ExpiredShutdownContext", func(t *testing.T) {
+		server := New("")
+		var hookErr error
+		server.OnShutdown(func(ctx context.Context) error {
+			hookErr = ctx.Err()
+			return nil
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		server.Shutdown(ctx)
+		assert.NoError(t, hookErr)
+	})
+
+	t.Run("Restart", func(t *testing.T) {
+		server := New("")
+		server.setBoundAddr(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8080})
+		assert.True(t, server.IsReady())
+		assert.NoError(t, server.Shutdown(context.Background()))
+		assert.False(t, server.IsReady())
+		server.setBoundAddr(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8080})
+		assert.True(t, server.IsReady())
+	})
+}
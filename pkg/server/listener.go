@@ -0,0 +1,191 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd socket activation.
+const listenFDsStart = 3
+
+// ErrNoSocketActivation is returned when the process was not started with
+// sockets passed through the LISTEN_FDS protocol.
+var ErrNoSocketActivation = errors.New("no socket activation file descriptors")
+
+// Serve accepts connections on ln, which can be any net.Listener,
+// and binds all registered routes and middleware.
+//
+//	ln, err := net.Listen("tcp", "127.0.0.1:0")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	log.Fatal(server.Serve(ln))
+func (s *Server) Serve(ln net.Listener) error {
+	return s.listen(func() ([]net.Listener, error) {
+		return []net.Listener{ln}, nil
+	}, s.httpServer.Serve, "http")
+}
+
+// ListenUnix listens on the Unix domain socket at path, replacing a stale
+// socket left by a previous run, and sets its permissions to mode.
+// A zero mode keeps the permissions given by the umask.
+//
+//	log.Fatal(server.ListenUnix("/run/app/app.sock", 0o660))
+func (s *Server) ListenUnix(path string, mode fs.FileMode) error {
+	return s.listen(func() ([]net.Listener, error) {
+		if info, err := os.Lstat(path); err == nil && info.Mode()&fs.ModeSocket != 0 {
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+		}
+		ln, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, err
+		}
+		if mode != 0 {
+			if err := os.Chmod(path, mode); err != nil {
+				ln.Close()
+				return nil, err
+			}
+		}
+		return []net.Listener{ln}, nil
+	}, s.httpServer.Serve, "http")
+}
+
+// ListenSystemd serves the sockets inherited through systemd socket
+// activation. Addr and Port report the first of them.
+//
+//	# app.socket
+//	[Socket]
+//	ListenStream=8080
+func (s *Server) ListenSystemd() error {
+	return s.listen(SystemdListeners, s.httpServer.Serve, "http")
+}
+
+// SystemdListeners returns the listeners passed to the process through
+// the LISTEN_PID, LISTEN_FDS and LISTEN_FDNAMES environment variables,
+// which are then unset so child processes do not inherit them.
+func SystemdListeners() ([]net.Listener, error) {
+	return inheritedListeners(listenFDsStart)
+}
+
+func inheritedListeners(start int) ([]net.Listener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, ErrNoSocketActivation
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, ErrNoSocketActivation
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	listeners := make([]net.Listener, 0, count)
+	for i := range count {
+		fd := start + i
+		name := fmt.Sprintf("LISTEN_FD_%d", fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		file := os.NewFile(uintptr(fd), name)
+		ln, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("listen fd %d (%s): %w", fd, name, err)
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+// Addr returns the address the server is bound to,
+// or nil when it is not listening.
+func (s *Server) Addr() net.Addr {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.boundAddr
+}
+
+// Ready returns a channel that is closed once the server is bound
+// and accepting connections.
+//
+//	go server.Listen()
+//	<-server.Ready()
+//	fmt.Println("listening on", server.Addr())
+func (s *Server) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// listen binds the listeners, reports their address and serves them
+// until the server is shut down.
+func (s *Server) listen(bind func() ([]net.Listener, error), serve func(net.Listener) error, scheme string) error {
+	if s.listenFn != nil {
+		return s.listenFn()
+	}
+	if err := s.runStartupHooks(); err != nil {
+		return err
+	}
+	listeners, err := bind()
+	if err != nil {
+		// The startup hooks may have opened resources already.
+		return errors.Join(err, s.runShutdownHooks(context.Background()))
+	}
+	if s.proxyProtocol != nil {
+		for i, ln := range listeners {
+			listeners[i] = NewProxyProtocolListener(ln, *s.proxyProtocol)
+		}
+	}
+	s.httpServer.Handler = s.Handler()
+	s.setBoundAddr(listeners[0].Addr())
+	if s.banner != nil {
+		if text := s.banner(s.displayAddr(scheme)); text != "" {
+			log.Println(text)
+		}
+	}
+
+	errCh := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		go func() {
+			errCh <- serve(ln)
+		}()
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// setBoundAddr records the address actually bound and signals readiness,
+// clearing the draining state of a previous shutdown.
+func (s *Server) setBoundAddr(addr net.Addr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.boundAddr = addr
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		s.port = strconv.Itoa(tcpAddr.Port)
+	}
+	s.MarkReady()
+}
+
+func (s *Server) displayAddr(scheme string) string {
+	if addr := s.Addr(); addr.Network() == "unix" {
+		return "unix:" + addr.String()
+	}
+	return fmt.Sprintf("%s://127.0.0.1:%s", scheme, s.Port())
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/i9si-sistemas/assert"
+)
+
+func startServer(t *testing.T, server *Server, listen func() error) {
+	t.Helper()
+	server.Get("/", func(c *Context) error {
+		return c.SendString("ok")
+	})
+	errCh := make(chan error, 1)
+	go func() { errCh <- listen() }()
+	select {
+	case <-server.Ready():
+	case err := <-errCh:
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server not ready")
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.NoError(t, server.Shutdown(ctx))
+		assert.NoError(t, <-errCh)
+	})
+}
+
+func get(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+	res, err := client.Get(url)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+	return string(body)
+}
+
+func TestListenRandomPort(t *testing.T) {
+	server := New("")
+	assert.Nil(t, server.Addr())
+	startServer(t, server, server.Listen)
+
+	port, err := strconv.Atoi(server.Port())
+	assert.NoError(t, err)
+	assert.True(t, port > 0)
+	assert.Equal(t, server.Addr().(*net.TCPAddr).Port, port)
+	assert.Equal(t, get(t, http.DefaultClient, fmt.Sprintf("http://127.0.0.1:%d/", port)), "ok")
+}
+
+func TestServe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	server := New(8080)
+	startServer(t, server, func() error { return server.Serve(ln) })
+
+	assert.Equal(t, server.Addr().String(), ln.Addr().String())
+	assert.Equal(t, server.Port(), strconv.Itoa(ln.Addr().(*net.TCPAddr).Port))
+	assert.Equal(t, get(t, http.DefaultClient, "http://"+ln.Addr().String()+"/"), "ok")
+}
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+	stale, err := net.Listen("unix", path)
+	assert.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	server := New("")
+	startServer(t, server, func() error { return server.ListenUnix(path, 0o600) })
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, info.Mode().Perm(), os.FileMode(0o600))
+	assert.Equal(t, server.Addr().Network(), "unix")
+	assert.Empty(t, server.Port())
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return new(net.Dialer).DialContext(ctx, "unix", path)
+		},
+	}}
+	assert.Equal(t, get(t, client, "http://unix/"), "ok")
+}
@@ -0,0 +1,51 @@
+//go:build unix
+
+package server
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/i9si-sistemas/assert"
+)
+
+func TestSystemdListeners(t *testing.T) {
+	t.Run("NotActivated", func(t *testing.T) {
+		t.Setenv("LISTEN_PID", "1")
+		t.Setenv("LISTEN_FDS", "1")
+		_, err := SystemdListeners()
+		assert.Equal(t, err, ErrNoSocketActivation)
+	})
+
+	t.Run("Inherited", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		defer ln.Close()
+		file, err := ln.(*net.TCPListener).File()
+		assert.NoError(t, err)
+		fd, err := syscall.Dup(int(file.Fd()))
+		assert.NoError(t, err)
+		file.Close()
+
+		t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+		t.Setenv("LISTEN_FDS", "1")
+		t.Setenv("LISTEN_FDNAMES", "http")
+		listeners, err := inheritedListeners(fd)
+		assert.NoError(t, err)
+		assert.Equal(t, len(listeners), 1)
+		assert.Equal(t, listeners[0].Addr().String(), ln.Addr().String())
+		assert.Empty(t, os.Getenv("LISTEN_FDS"))
+
+		server := New("")
+		startServer(t, server, func() error {
+			return server.listen(func() ([]net.Listener, error) {
+				return listeners, nil
+			}, server.httpServer.Serve, "http")
+		})
+		assert.Equal(t, get(t, http.DefaultClient, "http://"+ln.Addr().String()+"/"), "ok")
+	})
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"io/fs"
+	"net"
 )
 
 // RouteManager defines the interface for managing routes and groups.
@@ -71,6 +72,22 @@ type RouteManager interface {
 	//	 return c.Send([]byte(msg))
 	//})
 	Delete(endpoint string, handlers ...any) error
+	// WebSocket registers a WebSocket endpoint. The last handler must be a
+	// WebSocketHandler and the middlewares before it run ahead of the upgrade.
+	// Example:
+	//
+	//server.WebSocket("/echo", func(conn *i9.WebSocketConn) error {
+	//	for {
+	//		messageType, data, err := conn.ReadMessage()
+	//		if err != nil {
+	//			return err
+	//		}
+	//		if err := conn.WriteMessage(messageType, data); err != nil {
+	//			return err
+	//		}
+	//	}
+	//})
+	WebSocket(endpoint string, handlers ...any) error
 	// Route registers a route group with the specified pattern.
 	// Example:
 	//
@@ -112,14 +129,56 @@ type Manager interface {
 	//	// Serve embedded files under the root URL pattern "/"
 	//	server.ServeFilesWithFS("/", staticFiles)
 	ServeFilesWithFS(endpoint string, fs fs.FS)
+	// Metrics records HTTP metrics for every route and serves them, along
+	// with the metrics added to the returned registry, in the Prometheus
+	// text format at the specified endpoint.
+	// Example:
+	//
+	//registry, err := server.Metrics("/metrics")
+	Metrics(endpoint string, options ...MetricsConfig) (*Registry, error)
+	// AccessLog logs the requests of every route, including the ones
+	// answered by the middlewares of the routes and groups.
+	// Example:
+	//
+	//server.AccessLog(i9.AccessLogConfig{Format: i9.AccessLogJSON})
+	AccessLog(options ...AccessLogConfig)
+	// Health registers liveness and readiness endpoints backed by named checks.
+	// Example:
+	//
+	//server.Health(i9.HealthConfig{
+	//	Readiness: []i9.HealthCheck{{Name: "postgres", Check: db.PingContext}},
+	//})
+	Health(options ...HealthConfig) error
 	// Listen starts the HTTP server, listening on the configured address, and binds all registered routes and middleware.
 	Listen() error
 	// ListenTLS starts the HTTPS server, listening on the configured address, and binds all registered routes and middleware.
 	ListenTLS(certFile, keyFile string) error
+	// Serve accepts connections on the given listener.
+	Serve(ln net.Listener) error
+	// ListenUnix listens on a Unix domain socket with the given permissions.
+	ListenUnix(path string, mode fs.FileMode) error
+	// ListenSystemd serves the sockets inherited through systemd socket activation.
+	ListenSystemd() error
 	// Shutdown gracefully shuts down the server without interrupting any active connections.
 	Shutdown(ctx context.Context) error
+	// Run starts the server and shuts it down gracefully when ctx is canceled
+	// or the process receives SIGINT or SIGTERM.
+	Run(ctx context.Context) error
+	// OnStartup registers hooks run in order before the server starts listening.
+	OnStartup(hooks ...Hook)
+	// OnShutdown registers hooks run in order after the server stops.
+	OnShutdown(hooks ...Hook)
+	// MarkReady reports the server as ready, for servers started through
+	// ServerOpts.ListenFn.
+	MarkReady()
+	// IsReady reports whether the server is accepting requests and not draining.
+	IsReady() bool
 	// Test returns a test server for testing purposes.
 	Test() *TestServer
 	// Port returns the port the server is listening on.
 	Port() string
+	// Addr returns the address the server is bound to, or nil when it is not listening.
+	Addr() net.Addr
+	// Ready returns a channel closed once the server is accepting connections.
+	Ready() <-chan struct{}
 }
@@ -0,0 +1,486 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metricsContentType is the content type of the Prometheus text format.
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var (
+	// DefaultDurationBuckets are the buckets, in seconds, of the request
+	// duration histogram.
+	DefaultDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	// DefaultSizeBuckets are the buckets, in bytes, of the response size histogram.
+	DefaultSizeBuckets = []float64{100, 1_000, 10_000, 100_000, 1_000_000, 10_000_000}
+)
+
+var (
+	ErrInvalidMetric  = errors.New("invalid metric or label name")
+	ErrMetricConflict = errors.New("metric already registered with another type or labels")
+)
+
+var (
+	reMetricName = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	reLabelName  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+type metricType string
+
+const (
+	counterType   metricType = "counter"
+	gaugeType     metricType = "gauge"
+	histogramType metricType = "histogram"
+)
+
+// Registry holds metrics and writes them in the Prometheus text format.
+// It is an http.Handler serving that format.
+//
+//	registry := i9.NewRegistry()
+//	orders, _ := registry.Counter("orders_total", "Orders created.", "channel")
+//	orders.Inc("web")
+type Registry struct {
+	mu       sync.RWMutex
+	families map[string]*metricFamily
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]*metricFamily{}}
+}
+
+// Counter registers a counter, a value that only goes up, with the given
+// label names. Registering it again with the same labels returns the
+// existing counter.
+func (r *Registry) Counter(name, help string, labels ...string) (*Counter, error) {
+	f, err := r.register(name, help, counterType, nil, labels)
+	if err != nil {
+		return nil, err
+	}
+	return &Counter{f}, nil
+}
+
+// Gauge registers a gauge, a value that goes up and down, with the given
+// label names. Registering it again with the same labels returns the
+// existing gauge.
+func (r *Registry) Gauge(name, help string, labels ...string) (*Gauge, error) {
+	f, err := r.register(name, help, gaugeType, nil, labels)
+	if err != nil {
+		return nil, err
+	}
+	return &Gauge{f}, nil
+}
+
+// Histogram registers a histogram counting the observed values in buckets,
+// given by their upper bounds, with the given label names. The buckets
+// default to DefaultDurationBuckets. Registering it again with the same
+// labels returns the existing histogram.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) (*Histogram, error) {
+	if len(buckets) == 0 {
+		buckets = DefaultDurationBuckets
+	}
+	buckets = slices.Clone(buckets)
+	sort.Float64s(buckets)
+	buckets = slices.Compact(slices.DeleteFunc(buckets, func(b float64) bool {
+		return math.IsInf(b, 1) || math.IsNaN(b)
+	}))
+	if slices.Contains(labels, "le") {
+		return nil, fmt.Errorf("%w: %q is reserved in histograms", ErrInvalidMetric, "le")
+	}
+	f, err := r.register(name, help, histogramType, buckets, labels)
+	if err != nil {
+		return nil, err
+	}
+	return &Histogram{f}, nil
+}
+
+func (r *Registry) register(name, help string, typ metricType, buckets []float64, labels []string) (*metricFamily, error) {
+	if !reMetricName.MatchString(name) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidMetric, name)
+	}
+	for _, label := range labels {
+		if !reLabelName.MatchString(label) || strings.HasPrefix(label, "__") {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidMetric, label)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if f, ok := r.families[name]; ok {
+		if f.typ != typ || !slices.Equal(f.labels, labels) || !slices.Equal(f.buckets, buckets) {
+			return nil, fmt.Errorf("%w: %q", ErrMetricConflict, name)
+		}
+		return f, nil
+	}
+	f := &metricFamily{
+		name:    name,
+		help:    help,
+		typ:     typ,
+		labels:  slices.Clone(labels),
+		buckets: buckets,
+		series:  map[string]*metricSeries{},
+	}
+	r.families[name] = f
+	return f, nil
+}
+
+// WriteTo writes every metric in the Prometheus text format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.RLock()
+	families := make([]*metricFamily, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.RUnlock()
+	slices.SortFunc(families, func(a, b *metricFamily) int {
+		return strings.Compare(a.name, b.name)
+	})
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, f := range families {
+		f.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", metricsContentType)
+	r.WriteTo(w)
+}
+
+// Counter is a metric that only goes up, such as a number of requests.
+type Counter struct {
+	f *metricFamily
+}
+
+// Inc adds one to the counter with the given label values.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the counter with the given
+// label values.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	if s := c.f.get(labelValues); s != nil {
+		s.add(v)
+	}
+}
+
+// Gauge is a metric that goes up and down, such as a number of connections.
+type Gauge struct {
+	f *metricFamily
+}
+
+// Set sets the gauge with the given label values to v.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	if s := g.f.get(labelValues); s != nil {
+		s.value.Store(math.Float64bits(v))
+	}
+}
+
+// Add adds v, which can be negative, to the gauge with the given label values.
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	if s := g.f.get(labelValues); s != nil {
+		s.add(v)
+	}
+}
+
+// Inc adds one to the gauge with the given label values.
+func (g *Gauge) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+// Dec subtracts one from the gauge with the given label values.
+func (g *Gauge) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+// Histogram is a metric counting observations, such as request durations,
+// in buckets.
+type Histogram struct {
+	f *metricFamily
+}
+
+// Observe records v in the histogram with the given label values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	s := h.f.get(labelValues)
+	if s == nil {
+		return
+	}
+	i := sort.SearchFloat64s(h.f.buckets, v)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts[i]++
+	s.count++
+	s.sum += v
+}
+
+type metricFamily struct {
+	name, help string
+	typ        metricType
+	labels     []string
+	buckets    []float64
+
+	mu     sync.RWMutex
+	series map[string]*metricSeries
+}
+
+type metricSeries struct {
+	labelValues []string
+	// value holds the float64 bits of counters and gauges.
+	value atomic.Uint64
+
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// get returns the series with the given label values, creating it on first
+// use. Values given with the wrong number of labels are dropped.
+func (f *metricFamily) get(labelValues []string) *metricSeries {
+	if len(labelValues) != len(f.labels) {
+		return nil
+	}
+	key := strings.Join(labelValues, "\xff")
+	f.mu.RLock()
+	s, ok := f.series[key]
+	f.mu.RUnlock()
+	if ok {
+		return s
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if s, ok := f.series[key]; ok {
+		return s
+	}
+	s = &metricSeries{labelValues: slices.Clone(labelValues)}
+	if f.typ == histogramType {
+		s.counts = make([]uint64, len(f.buckets)+1)
+	}
+	f.series[key] = s
+	return s
+}
+
+func (s *metricSeries) add(v float64) {
+	for {
+		old := s.value.Load()
+		if s.value.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+func (f *metricFamily) write(w *bufio.Writer) {
+	f.mu.RLock()
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	series := make([]*metricSeries, len(keys))
+	for i, key := range keys {
+		series[i] = f.series[key]
+	}
+	f.mu.RUnlock()
+
+	if f.help != "" {
+		fmt.Fprintf(w, "# HELP %s %s\n", f.name, helpReplacer.Replace(f.help))
+	}
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.typ)
+	for _, s := range series {
+		if f.typ != histogramType {
+			f.sample(w, "", s.labelValues, "", "", math.Float64frombits(s.value.Load()))
+			continue
+		}
+		s.mu.Lock()
+		counts, count, sum := slices.Clone(s.counts), s.count, s.sum
+		s.mu.Unlock()
+		var cumulative uint64
+		for i, bound := range f.buckets {
+			cumulative += counts[i]
+			f.sample(w, "_bucket", s.labelValues, "le", formatFloat(bound), float64(cumulative))
+		}
+		f.sample(w, "_bucket", s.labelValues, "le", "+Inf", float64(count))
+		f.sample(w, "_sum", s.labelValues, "", "", sum)
+		f.sample(w, "_count", s.labelValues, "", "", float64(count))
+	}
+}
+
+// sample writes one line of the text format, with an extra label when
+// extraName is not empty.
+func (f *metricFamily) sample(w *bufio.Writer, suffix string, labelValues []string, extraName, extraValue string, v float64) {
+	w.WriteString(f.name)
+	w.WriteString(suffix)
+	if len(f.labels) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, label := range f.labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", label, labelReplacer.Replace(labelValues[i]))
+		}
+		if extraName != "" {
+			if len(f.labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extraName, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// MetricsConfig configures the HTTP metrics of Server.Metrics.
+type MetricsConfig struct {
+	// Registry receives the HTTP metrics. Defaults to a new Registry.
+	Registry *Registry
+	// Namespace prefixes the name of the HTTP metrics, as in
+	// "orders_http_requests_total".
+	Namespace string
+	// DurationBuckets defaults to DefaultDurationBuckets.
+	DurationBuckets []float64
+	// SizeBuckets defaults to DefaultSizeBuckets.
+	SizeBuckets []float64
+}
+
+// Metrics records the requests of every route and serves the metrics of the
+// returned registry in the Prometheus text format at endpoint:
+//
+//   - http_requests_total, by method, route and status class ("2xx").
+//   - http_request_duration_seconds, a histogram with the same labels.
+//   - http_response_size_bytes, a histogram with the same labels.
+//   - http_requests_in_flight, a gauge by method and route.
+//
+// The metrics wrap the middlewares of the routes and groups, so the requests
+// they reject, such as a 429 of RateLimit, are counted too.
+//
+// The route label is the registered pattern, such as "/users/{id}", never
+// the requested path, so the number of series stays bounded. Apps add their
+// own metrics to the returned registry.
+//
+//	registry, err := server.Metrics("/metrics")
+//	jobs, err := registry.Gauge("jobs_pending", "Jobs waiting in the queue.")
+func (s *Server) Metrics(endpoint string, options ...MetricsConfig) (*Registry, error) {
+	var config MetricsConfig
+	if len(options) > 0 {
+		config = options[0]
+	}
+	if config.Registry == nil {
+		config.Registry = NewRegistry()
+	}
+	m, err := newHTTPMetrics(config)
+	if err != nil {
+		return nil, err
+	}
+	s.useOuter(m.handle)
+	registry := config.Registry
+	err = s.Get(endpoint, func(req *Request, res *Response) error {
+		registry.ServeHTTP(res.HTTP(), req.HTTP())
+		return nil
+	})
+	return registry, err
+}
+
+type httpMetrics struct {
+	requests *Counter
+	duration *Histogram
+	size     *Histogram
+	inFlight *Gauge
+}
+
+func newHTTPMetrics(config MetricsConfig) (m *httpMetrics, err error) {
+	prefix := "http_"
+	if config.Namespace != "" {
+		prefix = config.Namespace + "_" + prefix
+	}
+	registry := config.Registry
+	m = new(httpMetrics)
+	labels := []string{"method", "route", "status"}
+	if m.requests, err = registry.Counter(prefix+"requests_total",
+		"Total number of HTTP requests.", labels...); err != nil {
+		return nil, err
+	}
+	if m.duration, err = registry.Histogram(prefix+"request_duration_seconds",
+		"Duration of the HTTP requests in seconds.", config.DurationBuckets, labels...); err != nil {
+		return nil, err
+	}
+	sizeBuckets := config.SizeBuckets
+	if len(sizeBuckets) == 0 {
+		sizeBuckets = DefaultSizeBuckets
+	}
+	if m.size, err = registry.Histogram(prefix+"response_size_bytes",
+		"Size of the HTTP responses in bytes.", sizeBuckets, labels...); err != nil {
+		return nil, err
+	}
+	if m.inFlight, err = registry.Gauge(prefix+"requests_in_flight",
+		"Number of HTTP requests being served.", "method", "route"); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *httpMetrics) handle(c *Context) error {
+	method, route := c.Request.Method(), routePath(c.PathRegistred())
+	m.inFlight.Inc(method, route)
+	defer m.inFlight.Dec(method, route)
+
+	start := time.Now()
+	w := newResponseWriter(c.Response.HTTP())
+	c.Response.ChangeResponseWriter(w)
+	c.Next()
+
+	status := strconv.Itoa(w.Status()/100) + "xx"
+	m.requests.Inc(method, route, status)
+	m.duration.Observe(time.Since(start).Seconds(), method, route, status)
+	m.size.Observe(float64(w.Bytes()), method, route, status)
+	return nil
+}
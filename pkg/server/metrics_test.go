@@ -0,0 +1,143 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/i9si-sistemas/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	orders, err := registry.Counter("orders_total", "Orders created.", "channel")
+	assert.NoError(t, err)
+	queue, err := registry.Gauge("queue_size", "Jobs\nwaiting.")
+	assert.NoError(t, err)
+	latency, err := registry.Histogram("job_seconds", "", []float64{1, 0.5, math.Inf(1)})
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			orders.Inc("web")
+		}()
+	}
+	wg.Wait()
+	orders.Add(2.5, `say "hi"`)
+	orders.Add(-1, "web")
+	orders.Inc()
+	queue.Set(10)
+	queue.Dec()
+	latency.Observe(0.2)
+	latency.Observe(0.7)
+	latency.Observe(3)
+
+	out := new(bytes.Buffer)
+	n, err := registry.WriteTo(out)
+	assert.NoError(t, err)
+	assert.Equal(t, n, int64(out.Len()))
+	assert.Equal(t, out.String(), `# TYPE job_seconds histogram
+job_seconds_bucket{le="0.5"} 1
+job_seconds_bucket{le="1"} 2
+job_seconds_bucket{le="+Inf"} 3
+job_seconds_sum 3.9
+job_seconds_count 3
+# HELP orders_total Orders created.
+# TYPE orders_total counter
+orders_total{channel="say \"hi\""} 2.5
+orders_total{channel="web"} 100
+# HELP queue_size Jobs\nwaiting.
+# TYPE queue_size gauge
+queue_size 9
+`)
+
+	again, err := registry.Counter("orders_total", "Orders created.", "channel")
+	assert.NoError(t, err)
+	again.Inc("web")
+	assert.True(t, strings.Contains(registry.text(), `orders_total{channel="web"} 101`))
+
+	_, err = registry.Gauge("orders_total", "")
+	assert.True(t, errors.Is(err, ErrMetricConflict))
+	_, err = registry.Counter("orders total", "")
+	assert.True(t, errors.Is(err, ErrInvalidMetric))
+	_, err = registry.Counter("requests", "", "__name")
+	assert.True(t, errors.Is(err, ErrInvalidMetric))
+	_, err = registry.Histogram("sizes", "", nil, "le")
+	assert.True(t, errors.Is(err, ErrInvalidMetric))
+}
+
+func (r *Registry) text() string {
+	var b strings.Builder
+	r.WriteTo(&b)
+	return b.String()
+}
+
+func TestServerMetrics(t *testing.T) {
+	server := New(0)
+	registry, err := server.Metrics("/metrics", MetricsConfig{Namespace: "shop"})
+	assert.NoError(t, err)
+	server.Get("/users/:id", func(c *Context) error {
+		return c.SendString("user")
+	})
+	server.Get("/fail", func(c *Context) error {
+		return &Error{StatusCode: http.StatusBadGateway, Err: errors.New("upstream")}
+	})
+	var inFlight string
+	server.Get("/in-flight", func(c *Context) error {
+		inFlight = registry.text()
+		return nil
+	})
+
+	for _, path := range []string{"/users/1", "/users/2", "/fail", "/in-flight"} {
+		server.Test().Request(httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	assert.True(t, strings.Contains(inFlight, `shop_http_requests_in_flight{method="GET",route="/in-flight"} 1`))
+
+	res := server.Test().Request(httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, res.Code, http.StatusOK)
+	assert.Equal(t, res.Header().Get("Content-Type"), metricsContentType)
+	body := res.Body.String()
+	for _, line := range []string{
+		`shop_http_requests_total{method="GET",route="/users/{id}",status="2xx"} 2`,
+		`shop_http_requests_total{method="GET",route="/fail",status="5xx"} 1`,
+		`shop_http_request_duration_seconds_count{method="GET",route="/users/{id}",status="2xx"} 2`,
+		`shop_http_response_size_bytes_bucket{method="GET",route="/users/{id}",status="2xx",le="100"} 2`,
+		`shop_http_response_size_bytes_sum{method="GET",route="/users/{id}",status="2xx"} 8`,
+		`shop_http_requests_in_flight{method="GET",route="/users/{id}"} 0`,
+	} {
+		assert.True(t, strings.Contains(body, line), line)
+	}
+	assert.False(t, strings.Contains(body, "/users/1"))
+
+	_, err = New(0).Metrics("/metrics", MetricsConfig{Registry: registry, Namespace: "shop"})
+	assert.NoError(t, err)
+}
+
+func TestServerMetricsGroupMiddlewares(t *testing.T) {
+	server := New(0)
+	registry, err := server.Metrics("/metrics")
+	assert.NoError(t, err)
+	api := server.Group("/api", RateLimit(RateLimitConfig{Limit: 1}))
+	api.Get("/orders", func(c *Context) error {
+		return c.SendString("orders")
+	})
+
+	for range 2 {
+		server.Test().Request(httptest.NewRequest(http.MethodGet, "/api/orders", nil))
+	}
+	body := registry.text()
+	for _, line := range []string{
+		`http_requests_total{method="GET",route="/api/orders",status="2xx"} 1`,
+		`http_requests_total{method="GET",route="/api/orders",status="4xx"} 1`,
+	} {
+		assert.True(t, strings.Contains(body, line), line)
+	}
+}
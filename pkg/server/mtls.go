@@ -0,0 +1,174 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+var (
+	// ErrClientCertRequired is returned when a request has no verified client certificate.
+	ErrClientCertRequired = errors.New("client certificate required")
+	// ErrClientCertForbidden is returned when a client certificate is not authorized.
+	ErrClientCertForbidden = errors.New("client certificate not authorized")
+)
+
+// LoadCertPool reads the PEM encoded certificates of files into a pool,
+// to be used as ServerOpts.ClientCAs.
+func LoadCertPool(files ...string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("%s: no certificates found", file)
+		}
+	}
+	return pool, nil
+}
+
+// clientAuthTLSConfig returns config with the client certificate
+// verification of opts applied.
+func clientAuthTLSConfig(config *tls.Config, opts ServerOpts) *tls.Config {
+	if opts.ClientCAs == nil && opts.ClientAuth == tls.NoClientCert {
+		return config
+	}
+	if config == nil {
+		config = new(tls.Config)
+	} else {
+		config = config.Clone()
+	}
+	if opts.ClientCAs != nil {
+		config.ClientCAs = opts.ClientCAs
+	}
+	if opts.ClientAuth != tls.NoClientCert {
+		config.ClientAuth = opts.ClientAuth
+	}
+	if config.ClientAuth == tls.NoClientCert {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config
+}
+
+// PeerIdentity is the identity of a client authenticated by a verified certificate.
+type PeerIdentity struct {
+	Subject        pkix.Name
+	DNSNames       []string
+	EmailAddresses []string
+	IPAddresses    []net.IP
+	URIs           []*url.URL
+	// SPIFFEID is the first spiffe:// URI SAN, empty when there is none.
+	SPIFFEID    string
+	Certificate *x509.Certificate
+}
+
+func newPeerIdentity(r *http.Request) *PeerIdentity {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	id := &PeerIdentity{
+		Subject:        cert.Subject,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		IPAddresses:    cert.IPAddresses,
+		URIs:           cert.URIs,
+		Certificate:    cert,
+	}
+	for _, uri := range cert.URIs {
+		if uri.Scheme == "spiffe" {
+			id.SPIFFEID = uri.String()
+			break
+		}
+	}
+	return id
+}
+
+// PeerIdentity returns the identity of the client certificate verified
+// during the TLS handshake, or nil when the request has none. Certificates
+// requested without verification, with tls.RequestClientCert or
+// tls.RequireAnyClientCert, are never reported.
+func (c *Context) PeerIdentity() *PeerIdentity {
+	return newPeerIdentity(c.Request.HTTP())
+}
+
+// ClientCertConfig authorizes requests by the attributes of their client
+// certificate. Every non-empty list must contain a value of the certificate.
+type ClientCertConfig struct {
+	CommonNames         []string
+	Organizations       []string
+	OrganizationalUnits []string
+	DNSNames            []string
+	// SPIFFEIDs lists the allowed SPIFFE IDs. An entry ending with "/"
+	// matches every ID under it, such as "spiffe://example.org/ns/prod/".
+	SPIFFEIDs []string
+	// Authorize is an additional check run after the lists.
+	Authorize func(id *PeerIdentity) bool
+}
+
+// ClientCert rejects requests without a verified client certificate with
+// 401 and those whose certificate is not authorized by config with 403.
+// The client certificate must be requested through ServerOpts.ClientCAs.
+//
+//	server := nine.NewServer(8443, i9.ServerOpts{ClientCAs: pool})
+//	internal := server.Group("/internal", i9.ClientCert(i9.ClientCertConfig{
+//		SPIFFEIDs: []string{"spiffe://example.org/ns/prod/"},
+//	}))
+func ClientCert(options ...ClientCertConfig) HandlerWithContext {
+	var config ClientCertConfig
+	if len(options) > 0 {
+		config = options[0]
+	}
+	return func(c *Context) error {
+		id := c.PeerIdentity()
+		if id == nil {
+			return &Error{StatusCode: http.StatusUnauthorized, Err: ErrClientCertRequired}
+		}
+		if !config.authorize(id) {
+			return &Error{StatusCode: http.StatusForbidden, Err: ErrClientCertForbidden}
+		}
+		return nil
+	}
+}
+
+func (config ClientCertConfig) authorize(id *PeerIdentity) bool {
+	matches := func(allowed, values []string) bool {
+		if len(allowed) == 0 {
+			return true
+		}
+		return slices.ContainsFunc(values, func(v string) bool {
+			return slices.Contains(allowed, v)
+		})
+	}
+	spiffeMatches := func() bool {
+		if len(config.SPIFFEIDs) == 0 {
+			return true
+		}
+		if id.SPIFFEID == "" {
+			return false
+		}
+		for _, allowed := range config.SPIFFEIDs {
+			if allowed == id.SPIFFEID ||
+				(strings.HasSuffix(allowed, "/") && strings.HasPrefix(id.SPIFFEID, allowed)) {
+				return true
+			}
+		}
+		return false
+	}
+	return matches(config.CommonNames, []string{id.Subject.CommonName}) &&
+		matches(config.Organizations, id.Subject.Organization) &&
+		matches(config.OrganizationalUnits, id.Subject.OrganizationalUnit) &&
+		matches(config.DNSNames, id.DNSNames) &&
+		spiffeMatches() &&
+		(config.Authorize == nil || config.Authorize(id))
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/i9si-sistemas/assert"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return &testCA{cert: cert, key: key}
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+func (ca *testCA) issue(t *testing.T, template *x509.Certificate) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	assert.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestLoadCertPool(t *testing.T) {
+	ca := newTestCA(t)
+	file := filepath.Join(t.TempDir(), "ca.pem")
+	assert.NoError(t, os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0o600))
+	pool, err := LoadCertPool(file)
+	assert.NoError(t, err)
+	assert.True(t, pool.Equal(ca.pool()))
+
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	assert.NoError(t, os.WriteFile(empty, nil, 0o600))
+	_, err = LoadCertPool(empty)
+	assert.NotNil(t, err)
+}
+
+func TestClientCert(t *testing.T) {
+	ca := newTestCA(t)
+	serverCert := ca.issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "server"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	})
+	spiffeID, _ := url.Parse("spiffe://example.org/ns/prod/sa/billing")
+	billing := ca.issue(t, &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "billing", Organization: []string{"Example"}},
+		DNSNames: []string{"billing.internal"},
+		URIs:     []*url.URL{spiffeID},
+	})
+	reports := ca.issue(t, &x509.Certificate{
+		Subject: pkix.Name{CommonName: "reports"},
+	})
+
+	server := New("", ServerOpts{
+		TLSConfig:  &tls.Config{Certificates: []tls.Certificate{serverCert}},
+		ClientCAs:  ca.pool(),
+		ClientAuth: tls.VerifyClientCertIfGiven,
+	})
+	server.Get("/whoami", func(c *Context) error {
+		id := c.PeerIdentity()
+		if id == nil {
+			return c.SendString("anonymous")
+		}
+		return c.SendString(id.Subject.CommonName + " " + id.SPIFFEID)
+	})
+	server.Get("/internal", ClientCert(), func(c *Context) error {
+		return c.SendString("internal")
+	})
+	server.Get("/billing", ClientCert(ClientCertConfig{
+		Organizations: []string{"Example"},
+		SPIFFEIDs:     []string{"spiffe://example.org/ns/prod/"},
+		Authorize: func(id *PeerIdentity) bool {
+			return id.DNSNames[0] == "billing.internal"
+		},
+	}), func(c *Context) error {
+		return c.SendString("billing")
+	})
+	startServer(t, server, func() error { return server.ListenTLS("", "") })
+
+	client := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      ca.pool(),
+			Certificates: certs,
+		}}}
+	}
+	status := func(client *http.Client, path string) int {
+		res, err := client.Get("https://127.0.0.1:" + server.Port() + path)
+		assert.NoError(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+	base := "https://127.0.0.1:" + server.Port()
+
+	assert.Equal(t, get(t, client(), base+"/whoami"), "anonymous")
+	assert.Equal(t, get(t, client(billing), base+"/whoami"), "billing spiffe://example.org/ns/prod/sa/billing")
+	assert.Equal(t, get(t, client(reports), base+"/whoami"), "reports ")
+
+	assert.Equal(t, status(client(), "/internal"), http.StatusUnauthorized)
+	assert.Equal(t, status(client(reports), "/internal"), http.StatusOK)
+	assert.Equal(t, status(client(reports), "/billing"), http.StatusForbidden)
+	assert.Equal(t, get(t, client(billing), base+"/billing"), "billing")
+
+	untrusted := newTestCA(t).issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "billing"}})
+	_, err := client(untrusted).Get(base + "/whoami")
+	assert.NotNil(t, err)
+}
+
+func TestClientAuthTLSConfig(t *testing.T) {
+	assert.True(t, clientAuthTLSConfig(nil, ServerOpts{}) == nil)
+
+	base := &tls.Config{MinVersion: tls.VersionTLS13}
+	pool := x509.NewCertPool()
+	config := clientAuthTLSConfig(base, ServerOpts{ClientCAs: pool})
+	assert.Equal(t, config.ClientAuth, tls.RequireAndVerifyClientCert)
+	assert.Equal(t, config.MinVersion, uint16(tls.VersionTLS13))
+	assert.True(t, config.ClientCAs == pool)
+	assert.Equal(t, base.ClientAuth, tls.NoClientCert)
+
+	base = &tls.Config{ClientCAs: pool, ClientAuth: tls.VerifyClientCertIfGiven}
+	config = clientAuthTLSConfig(base, ServerOpts{ClientAuth: tls.RequireAndVerifyClientCert})
+	assert.True(t, config.ClientCAs == pool)
+	assert.Equal(t, config.ClientAuth, tls.RequireAndVerifyClientCert)
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// forwardedHop is a single address of the chain a request went through,
+// along with the scheme and host it was received with, when known.
+type forwardedHop struct {
+	raw   string
+	addr  netip.Addr
+	proto string
+	host  string
+}
+
+// String returns the normalized address of the hop, or its raw value
+// when it is not an IP address.
+func (h forwardedHop) String() string {
+	if h.addr.IsValid() {
+		return h.addr.String()
+	}
+	return h.raw
+}
+
+// trustsProxy reports whether addr belongs to one of the trusted proxy ranges.
+func (s *Server) trustsProxy(addr netip.Addr) bool {
+	if s == nil || !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range s.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardingChain returns the hops of r, ending at the peer connected to the
+// server, and the index of the client. Forwarding headers are only read when
+// the peer is a trusted proxy, and the chain is walked from right to left
+// until the first address that is not trusted, so clients can not spoof it.
+func forwardingChain(r *http.Request) (hops []forwardedHop, client int) {
+	s := serverFromRequest(r)
+	peer := newForwardedHop(r.RemoteAddr)
+	if !s.trustsProxy(peer.addr) {
+		return []forwardedHop{peer}, 0
+	}
+
+	if values := r.Header.Values("Forwarded"); len(values) > 0 {
+		hops = parseForwarded(values)
+	} else if values := r.Header.Values("X-Forwarded-For"); len(values) > 0 {
+		for _, value := range values {
+			for _, entry := range splitComma(value) {
+				hops = append(hops, newForwardedHop(entry))
+			}
+		}
+	} else if ip := r.Header.Get("X-Real-IP"); ip != "" {
+		hops = append(hops, newForwardedHop(ip))
+	}
+	hops = append(hops, peer)
+
+	client = len(hops) - 1
+	for client > 0 && s.trustsProxy(hops[client].addr) {
+		client--
+	}
+	if !hops[client].addr.IsValid() {
+		client++
+	}
+	return hops, client
+}
+
+func newForwardedHop(value string) forwardedHop {
+	value = strings.Trim(strings.TrimSpace(value), `"`)
+	hop := forwardedHop{raw: value}
+	if addrPort, err := netip.ParseAddrPort(value); err == nil {
+		hop.addr = addrPort.Addr().Unmap()
+	} else if addr, err := netip.ParseAddr(strings.Trim(value, "[]")); err == nil {
+		hop.addr = addr.Unmap()
+	}
+	return hop
+}
+
+// parseForwarded parses the elements of RFC 7239 Forwarded headers.
+func parseForwarded(values []string) []forwardedHop {
+	var hops []forwardedHop
+	for _, value := range values {
+		for _, element := range splitQuoted(value, ',') {
+			var hop forwardedHop
+			for _, pair := range splitQuoted(element, ';') {
+				key, val, ok := strings.Cut(pair, "=")
+				if !ok {
+					continue
+				}
+				val = strings.Trim(strings.TrimSpace(val), `"`)
+				switch strings.ToLower(strings.TrimSpace(key)) {
+				case "for":
+					proto, host := hop.proto, hop.host
+					hop = newForwardedHop(val)
+					hop.proto, hop.host = proto, host
+				case "proto":
+					hop.proto = strings.ToLower(val)
+				case "host":
+					hop.host = val
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// splitQuoted splits s on sep, ignoring the separators inside quoted strings.
+func splitQuoted(s string, sep byte) []string {
+	var (
+		parts    []string
+		start    int
+		inQuotes bool
+	)
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if inQuotes {
+				i++
+			}
+		case '"':
+			inQuotes = !inQuotes
+		case sep:
+			if !inQuotes {
+				parts = append(parts, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+// lastComma returns the rightmost entry of a comma-separated header,
+// which was written by the proxy closest to the server.
+func lastComma(value string) string {
+	parts := splitComma(value)
+	return parts[len(parts)-1]
+}
+
+func stripPort(hostport string) string {
+	if host, _, err := net.SplitHostPort(hostport); err == nil {
+		return host
+	}
+	return strings.Trim(hostport, "[]")
+}
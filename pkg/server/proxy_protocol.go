@@ -0,0 +1,256 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultProxyHeaderTimeout is the time allowed to read a PROXY protocol
+// header when ProxyProtocolConfig.ReadHeaderTimeout is zero.
+const DefaultProxyHeaderTimeout = 5 * time.Second
+
+// ErrInvalidProxyHeader is returned when a connection sends a malformed PROXY protocol header.
+var ErrInvalidProxyHeader = errors.New("invalid proxy protocol header")
+
+// ErrMissingProxyHeader is returned when a required PROXY protocol header is missing.
+var ErrMissingProxyHeader = errors.New("missing proxy protocol header")
+
+// proxyV1Prefix starts every PROXY protocol v1 header.
+const proxyV1Prefix = "PROXY "
+
+// proxyV2Signature starts every PROXY protocol v2 header.
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// ProxyProtocolConfig configures the HAProxy PROXY protocol (v1 and v2)
+// support of a listener.
+type ProxyProtocolConfig struct {
+	// TrustedSources lists the load balancer networks allowed to send a
+	// PROXY header. Connections from other addresses are served as is,
+	// so the header is ignored on every connection when it is empty.
+	//
+	//	TrustedSources: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	TrustedSources []netip.Prefix
+	// ReadHeaderTimeout bounds the time spent reading the header.
+	// Defaults to DefaultProxyHeaderTimeout.
+	ReadHeaderTimeout time.Duration
+	// Required rejects connections from trusted sources without a header.
+	Required bool
+}
+
+// NewProxyProtocolListener wraps ln so that the connections accepted from
+// trusted sources report, through RemoteAddr and LocalAddr, the addresses
+// sent in their PROXY protocol header.
+//
+// The header is read when the connection is first read or asked for its
+// addresses, which net/http does from the goroutine serving it, so slow
+// clients never block Accept. The ConnState and ConnContext hooks of
+// http.Server run in the accepting goroutine though: when they call
+// RemoteAddr or LocalAddr, a client that holds back its header stalls
+// Accept for up to ReadHeaderTimeout.
+func NewProxyProtocolListener(ln net.Listener, config ProxyProtocolConfig) net.Listener {
+	if config.ReadHeaderTimeout <= 0 {
+		config.ReadHeaderTimeout = DefaultProxyHeaderTimeout
+	}
+	return &proxyProtocolListener{Listener: ln, config: config}
+}
+
+type proxyProtocolListener struct {
+	net.Listener
+	config ProxyProtocolConfig
+}
+
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.trusts(conn.RemoteAddr()) {
+		return conn, nil
+	}
+	return &proxyProtocolConn{
+		Conn:   conn,
+		config: l.config,
+		br:     bufio.NewReader(conn),
+	}, nil
+}
+
+func (l *proxyProtocolListener) trusts(addr net.Addr) bool {
+	addrPort, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return false
+	}
+	for _, prefix := range l.config.TrustedSources {
+		if prefix.Contains(addrPort.Addr().Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+type proxyProtocolConn struct {
+	net.Conn
+	config ProxyProtocolConfig
+	br     *bufio.Reader
+
+	once          sync.Once
+	err           error
+	remote, local net.Addr
+}
+
+func (c *proxyProtocolConn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.br.Read(b)
+}
+
+// RemoteAddr returns the client address sent by the proxy,
+// or the address of the connection when none was sent.
+// It blocks until the header is read or ReadHeaderTimeout expires.
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the destination address sent by the proxy,
+// or the address of the connection when none was sent.
+// It blocks as RemoteAddr.
+func (c *proxyProtocolConn) LocalAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.local != nil {
+		return c.local
+	}
+	return c.Conn.LocalAddr()
+}
+
+func (c *proxyProtocolConn) readHeader() {
+	c.Conn.SetReadDeadline(time.Now().Add(c.config.ReadHeaderTimeout))
+	defer c.Conn.SetReadDeadline(time.Time{})
+
+	prefix, err := c.br.Peek(len(proxyV1Prefix))
+	if len(prefix) == 0 {
+		c.err = err
+		return
+	}
+	switch {
+	case string(prefix) == proxyV1Prefix:
+		c.err = c.readV1()
+	case prefix[0] == proxyV2Signature[0]:
+		c.err = c.readV2()
+	default:
+		if c.config.Required {
+			c.err = ErrMissingProxyHeader
+		}
+	}
+}
+
+// readV1 parses a text header such as "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n".
+func (c *proxyProtocolConn) readV1() error {
+	const maxLength = 107
+	var line []byte
+	for {
+		b, err := c.br.ReadByte()
+		if err != nil {
+			return err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+		if len(line) >= maxLength {
+			return ErrInvalidProxyHeader
+		}
+	}
+	fields := strings.Fields(strings.TrimSuffix(string(line), "\r\n"))
+	if len(fields) < 2 || fields[0] != "PROXY" || !bytes.HasSuffix(line, []byte("\r\n")) {
+		return ErrInvalidProxyHeader
+	}
+	if fields[1] == "UNKNOWN" {
+		return nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return ErrInvalidProxyHeader
+	}
+	src, err := parseProxyAddr(fields[2], fields[4])
+	if err != nil {
+		return err
+	}
+	dst, err := parseProxyAddr(fields[3], fields[5])
+	if err != nil {
+		return err
+	}
+	if src.IP.To4() == nil != (fields[1] == "TCP6") {
+		return ErrInvalidProxyHeader
+	}
+	c.remote, c.local = src, dst
+	return nil
+}
+
+func parseProxyAddr(ip, port string) (*net.TCPAddr, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil, ErrInvalidProxyHeader
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, ErrInvalidProxyHeader
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, uint16(p))), nil
+}
+
+// readV2 parses a binary header. Only the addresses of TCP and UDP over
+// IPv4 and IPv6 are used; TLVs and other families are skipped.
+func (c *proxyProtocolConn) readV2() error {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(c.br, header); err != nil {
+		return err
+	}
+	if !bytes.Equal(header[:12], proxyV2Signature) || header[12]>>4 != 2 {
+		return ErrInvalidProxyHeader
+	}
+	command, family := header[12]&0x0f, header[13]
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:]))
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return err
+	}
+	switch command {
+	case 0x0: // LOCAL: health checks from the proxy itself.
+		return nil
+	case 0x1: // PROXY
+	default:
+		return ErrInvalidProxyHeader
+	}
+
+	var size int
+	switch family >> 4 {
+	case 0x1:
+		size = 4
+	case 0x2:
+		size = 16
+	default:
+		return nil
+	}
+	if len(payload) < 2*size+4 {
+		return ErrInvalidProxyHeader
+	}
+	srcIP, _ := netip.AddrFromSlice(payload[:size])
+	dstIP, _ := netip.AddrFromSlice(payload[size : 2*size])
+	srcPort := binary.BigEndian.Uint16(payload[2*size:])
+	dstPort := binary.BigEndian.Uint16(payload[2*size+2:])
+	c.remote = net.TCPAddrFromAddrPort(netip.AddrPortFrom(srcIP, srcPort))
+	c.local = net.TCPAddrFromAddrPort(netip.AddrPortFrom(dstIP, dstPort))
+	return nil
+}
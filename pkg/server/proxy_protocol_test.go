@@ -0,0 +1,174 @@
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/i9si-sistemas/assert"
+)
+
+func TestProxyProtocol(t *testing.T) {
+	loopback := []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}
+	newListener := func(t *testing.T, config ProxyProtocolConfig) net.Listener {
+		server := New(0)
+		handler := func(c *Context) error {
+			return c.SendString(c.IP() + " " + c.Request.HTTP().RemoteAddr)
+		}
+		server.Get("/", handler)
+		server.Post("/", handler)
+		server.Put("/", handler)
+		server.Patch("/", handler)
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		if config.TrustedSources == nil {
+			config.TrustedSources = loopback
+		}
+		ln = NewProxyProtocolListener(ln, config)
+		srv := &http.Server{Handler: server.Handler()}
+		go srv.Serve(ln)
+		t.Cleanup(func() { srv.Close() })
+		return ln
+	}
+	request := func(t *testing.T, ln net.Listener, header []byte, method ...string) (*http.Response, string, error) {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		assert.NoError(t, err)
+		defer conn.Close()
+		conn.Write(header)
+		if len(method) == 0 {
+			method = []string{http.MethodGet}
+		}
+		conn.Write([]byte(method[0] + " / HTTP/1.1\r\nHost: example.com\r\nContent-Length: 0\r\nConnection: close\r\n\r\n"))
+		res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			return nil, "", err
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return res, string(body), nil
+	}
+
+	t.Run("V1", func(t *testing.T) {
+		ln := newListener(t, ProxyProtocolConfig{})
+		_, body, err := request(t, ln, []byte("PROXY TCP4 198.51.100.4 192.0.2.1 56324 443\r\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, body, "198.51.100.4 198.51.100.4:56324")
+
+		_, body, err = request(t, ln, []byte("PROXY TCP6 2001:db8::1 2001:db8::2 4000 443\r\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, body, "2001:db8::1 [2001:db8::1]:4000")
+	})
+
+	t.Run("V2", func(t *testing.T) {
+		ln := newListener(t, ProxyProtocolConfig{})
+		header := append([]byte{}, proxyV2Signature...)
+		header = append(header, 0x21, 0x11, 0, 12)
+		header = append(header, 203, 0, 113, 9, 192, 0, 2, 1)
+		header = binary.BigEndian.AppendUint16(header, 40000)
+		header = binary.BigEndian.AppendUint16(header, 443)
+		_, body, err := request(t, ln, header)
+		assert.NoError(t, err)
+		assert.Equal(t, body, "203.0.113.9 203.0.113.9:40000")
+	})
+
+	t.Run("V2Local", func(t *testing.T) {
+		ln := newListener(t, ProxyProtocolConfig{})
+		header := append([]byte{}, proxyV2Signature...)
+		header = append(header, 0x20, 0x00, 0, 0)
+		_, body, err := request(t, ln, header)
+		assert.NoError(t, err)
+		assert.Equal(t, body[:len("127.0.0.1 ")], "127.0.0.1 ")
+	})
+
+	t.Run("WithoutHeader", func(t *testing.T) {
+		ln := newListener(t, ProxyProtocolConfig{})
+		_, body, err := request(t, ln, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, body[:len("127.0.0.1 ")], "127.0.0.1 ")
+
+		for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch} {
+			_, body, err = request(t, ln, nil, method)
+			assert.NoError(t, err, method)
+			assert.Equal(t, body[:len("127.0.0.1 ")], "127.0.0.1 ", method)
+		}
+
+		ln = newListener(t, ProxyProtocolConfig{Required: true})
+		res, _, err := request(t, ln, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, res.StatusCode, http.StatusBadRequest)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		ln := newListener(t, ProxyProtocolConfig{})
+		res, _, err := request(t, ln, []byte("PROXY TCP4 nope 192.0.2.1 1 2\r\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, res.StatusCode, http.StatusBadRequest)
+	})
+
+	t.Run("UntrustedSource", func(t *testing.T) {
+		ln := newListener(t, ProxyProtocolConfig{
+			TrustedSources: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		})
+		res, _, err := request(t, ln, []byte("PROXY TCP4 198.51.100.4 192.0.2.1 56324 443\r\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, res.StatusCode, http.StatusBadRequest)
+	})
+
+	t.Run("NoTrustedSources", func(t *testing.T) {
+		ln := newListener(t, ProxyProtocolConfig{TrustedSources: []netip.Prefix{}})
+		res, _, err := request(t, ln, []byte("PROXY TCP4 198.51.100.4 192.0.2.1 56324 443\r\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, res.StatusCode, http.StatusBadRequest)
+	})
+
+	t.Run("ReadHeaderTimeout", func(t *testing.T) {
+		ln := newListener(t, ProxyProtocolConfig{ReadHeaderTimeout: 50 * time.Millisecond})
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		assert.NoError(t, err)
+		defer conn.Close()
+		conn.Write([]byte("PROXY TCP4"))
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, err = conn.Read(make([]byte, 1))
+		assert.Equal(t, err, io.EOF)
+	})
+
+	t.Run("ConnStateHook", func(t *testing.T) {
+		server := New(0)
+		server.Get("/", func(c *Context) error {
+			return c.SendString(c.Request.HTTP().RemoteAddr)
+		})
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		ln = NewProxyProtocolListener(ln, ProxyProtocolConfig{
+			TrustedSources:    loopback,
+			ReadHeaderTimeout: 100 * time.Millisecond,
+		})
+		hooked := make(chan string, 2)
+		srv := &http.Server{
+			Handler: server.Handler(),
+			ConnState: func(c net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					hooked <- c.RemoteAddr().String()
+				}
+			},
+		}
+		go srv.Serve(ln)
+		t.Cleanup(func() { srv.Close() })
+
+		silent, err := net.Dial("tcp", ln.Addr().String())
+		assert.NoError(t, err)
+		defer silent.Close()
+		start := time.Now()
+		_, body, err := request(t, ln, []byte("PROXY TCP4 198.51.100.4 192.0.2.1 56324 443\r\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, body, "198.51.100.4:56324")
+		assert.Equal(t, <-hooked, silent.LocalAddr().String())
+		assert.Equal(t, <-hooked, "198.51.100.4:56324")
+		assert.True(t, time.Since(start) < time.Second)
+	})
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/i9si-sistemas/assert"
+	"github.com/i9si-sistemas/nine/internal/json"
+)
+
+func TestTrustedProxies(t *testing.T) {
+	server := New(0, ServerOpts{
+		TrustedProxies: []netip.Prefix{
+			netip.MustParsePrefix("10.0.0.0/8"),
+			netip.MustParsePrefix("2001:db8::/32"),
+		},
+	})
+	server.Get("/", func(c *Context) error {
+		return c.JSON(JSON{
+			"ip":       c.IP(),
+			"ips":      c.IPs(),
+			"protocol": c.Protocol(),
+			"hostname": c.Hostname(),
+		})
+	})
+	request := func(remoteAddr string, header http.Header) JSON {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com:8080/", nil)
+		req.RemoteAddr = remoteAddr
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		res := server.Test().Request(req)
+		var payload JSON
+		assert.NoError(t, json.Decode(res.Body.Bytes(), &payload))
+		return payload
+	}
+
+	t.Run("UntrustedPeer", func(t *testing.T) {
+		payload := request("203.0.113.7:5555", http.Header{
+			"X-Forwarded-For":   {"1.1.1.1"},
+			"X-Real-Ip":         {"1.1.1.1"},
+			"X-Forwarded-Proto": {"https"},
+			"X-Forwarded-Host":  {"evil.com"},
+		})
+		assert.Equal(t, payload["ip"], "203.0.113.7")
+		assert.Equal(t, payload["ips"], []any{"203.0.113.7"})
+		assert.Equal(t, payload["protocol"], "http")
+		assert.Equal(t, payload["hostname"], "example.com")
+	})
+
+	t.Run("XForwardedFor", func(t *testing.T) {
+		payload := request("10.0.0.1:5555", http.Header{
+			"X-Forwarded-For":   {"6.6.6.6, 198.51.100.4", "10.0.0.2"},
+			"X-Forwarded-Proto": {"http, https"},
+			"X-Forwarded-Host":  {"api.example.com:443"},
+		})
+		assert.Equal(t, payload["ip"], "198.51.100.4")
+		assert.Equal(t, payload["ips"], []any{"198.51.100.4", "10.0.0.2", "10.0.0.1"})
+		assert.Equal(t, payload["protocol"], "https")
+		assert.Equal(t, payload["hostname"], "api.example.com")
+	})
+
+	t.Run("ForwardedProtoOnly", func(t *testing.T) {
+		payload := request("10.0.0.1:5555", http.Header{
+			"X-Forwarded-Proto": {"https"},
+			"X-Forwarded-Host":  {"api.example.com"},
+		})
+		assert.Equal(t, payload["ip"], "10.0.0.1")
+		assert.Equal(t, payload["protocol"], "https")
+		assert.Equal(t, payload["hostname"], "api.example.com")
+	})
+
+	t.Run("AllTrusted", func(t *testing.T) {
+		payload := request("10.0.0.1:5555", http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}})
+		assert.Equal(t, payload["ip"], "10.0.0.3")
+	})
+
+	t.Run("XRealIP", func(t *testing.T) {
+		payload := request("10.0.0.1:5555", http.Header{"X-Real-Ip": {"198.51.100.9"}})
+		assert.Equal(t, payload["ip"], "198.51.100.9")
+	})
+
+	t.Run("Forwarded", func(t *testing.T) {
+		payload := request("[2001:db8::1]:5555", http.Header{
+			"Forwarded": {`for=6.6.6.6, for="[2001:dead::17]:4711";proto=https;host="shop.example.com"`, "for=10.1.2.3"},
+		})
+		assert.Equal(t, payload["ip"], "2001:dead::17")
+		assert.Equal(t, payload["protocol"], "https")
+		assert.Equal(t, payload["hostname"], "shop.example.com")
+	})
+
+	t.Run("ObfuscatedForwarded", func(t *testing.T) {
+		payload := request("10.0.0.1:5555", http.Header{"Forwarded": {"for=unknown, for=10.0.0.9"}})
+		assert.Equal(t, payload["ip"], "10.0.0.9")
+	})
+}
+
+func TestContextProtocol(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+	req.TLS = &tls.ConnectionState{}
+	c := NewContext(req.Context(), req, httptest.NewRecorder())
+	assert.Equal(t, c.Protocol(), "https")
+	assert.Equal(t, c.Hostname(), "example.com")
+}
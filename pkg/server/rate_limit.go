@@ -0,0 +1,319 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"hash/maphash"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of RateLimitConfig.
+const (
+	DefaultRateLimit       = 60
+	DefaultRateLimitWindow = time.Minute
+)
+
+// ErrRateLimited is the error of the requests refused by RateLimit.
+var ErrRateLimited = errors.New("too many requests")
+
+// RateLimitAlgorithm is the algorithm of the RateLimit middleware.
+type RateLimitAlgorithm int
+
+const (
+	// TokenBucket refills RateLimitConfig.Limit tokens per window, up to
+	// RateLimitConfig.Burst, and every request takes one. It allows short
+	// bursts while enforcing the average rate.
+	TokenBucket RateLimitAlgorithm = iota
+	// SlidingWindow allows RateLimitConfig.Limit requests in any window,
+	// weighting the count of the previous window by its overlap with it.
+	SlidingWindow
+)
+
+// RateLimitState is the state kept for every key by a RateLimitStore.
+// Its fields are interpreted by the algorithm: the tokens left and the
+// time of the last refill for TokenBucket, the counts of the current and
+// previous windows and the start of the current one for SlidingWindow.
+type RateLimitState struct {
+	Value    float64
+	Previous float64
+	Time     time.Time
+}
+
+// RateLimitStore keeps the rate limit states, in memory or in a shared
+// backend so the limits hold across instances.
+type RateLimitStore interface {
+	// Update atomically applies fn to the state of key, which is the zero
+	// value when the key is missing or expired, and keeps the result for ttl.
+	Update(ctx context.Context, key string, ttl time.Duration, fn func(state *RateLimitState)) error
+}
+
+// RateLimitConfig configures the RateLimit middleware.
+type RateLimitConfig struct {
+	Algorithm RateLimitAlgorithm
+	// Limit is the number of requests allowed per Window.
+	// Defaults to DefaultRateLimit.
+	Limit int
+	// Window defaults to DefaultRateLimitWindow.
+	Window time.Duration
+	// Burst is the capacity of the token bucket. Defaults to Limit.
+	Burst int
+	// Key returns the key the requests are counted by. Defaults to KeyByIP.
+	// Keys read from the request must be authenticated before RateLimit
+	// runs, or clients can get fresh limits by changing them.
+	Key func(c *Context) string
+	// Store defaults to a new MemoryRateLimitStore.
+	Store RateLimitStore
+	// Prefix is prepended to the keys, to share a Store between limits.
+	Prefix string
+	// Skip reports whether a request is not limited.
+	Skip func(c *Context) bool
+}
+
+// KeyByIP counts the requests by client IP, see Context.IP.
+func KeyByIP(c *Context) string {
+	return c.IP()
+}
+
+// KeyByHeader counts the requests by the value of a request header,
+// falling back to the client IP when the header is missing. Clients can
+// send made-up values to get fresh limits, so use it only after a
+// middleware that rejects the requests whose value is not authenticated.
+func KeyByHeader(name string) func(c *Context) string {
+	return func(c *Context) string {
+		if value := c.Request.Header(name); value != "" {
+			return name + ":" + value
+		}
+		return KeyByIP(c)
+	}
+}
+
+// KeyByAPIKey counts the requests by API key, read from the X-Api-Key
+// header or from a bearer Authorization header, falling back to the
+// client IP for anonymous requests. Clients can rotate made-up keys to get
+// fresh limits, so add RateLimit after the middleware that validates the
+// keys, as in the example of RateLimit.
+func KeyByAPIKey(c *Context) string {
+	key := c.Request.Header("X-Api-Key")
+	if key == "" {
+		auth := c.Request.Header("Authorization")
+		if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+			key = auth[7:]
+		}
+	}
+	if key == "" {
+		return KeyByIP(c)
+	}
+	return "key:" + key
+}
+
+// RateLimit limits the rate of requests by key, the client IP by default.
+// It sets the RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and
+// RateLimit-Policy headers and refuses the requests over the limit with
+// 429 Too Many Requests and a Retry-After header. Added to a route or a
+// group, the limit only applies to it. Requests are allowed when the store
+// fails.
+//
+//	api := server.Group("/api", authenticateAPIKey, i9.RateLimit(i9.RateLimitConfig{
+//		Limit: 100,
+//		Key:   i9.KeyByAPIKey,
+//	}))
+func RateLimit(options ...RateLimitConfig) HandlerWithContext {
+	var config RateLimitConfig
+	if len(options) > 0 {
+		config = options[0]
+	}
+	if config.Limit <= 0 {
+		config.Limit = DefaultRateLimit
+	}
+	if config.Window <= 0 {
+		config.Window = DefaultRateLimitWindow
+	}
+	if config.Burst <= 0 {
+		config.Burst = config.Limit
+	}
+	if config.Key == nil {
+		config.Key = KeyByIP
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryRateLimitStore()
+	}
+	policy := strconv.Itoa(config.Limit) + ";w=" + strconv.Itoa(int(math.Ceil(config.Window.Seconds())))
+	if config.Algorithm == TokenBucket && config.Burst != config.Limit {
+		policy += ";burst=" + strconv.Itoa(config.Burst)
+	}
+
+	return func(c *Context) error {
+		if config.Skip != nil && config.Skip(c) {
+			return nil
+		}
+		var result rateLimitResult
+		now := time.Now()
+		ttl := 2 * config.Window
+		if config.Algorithm == TokenBucket {
+			ttl = time.Duration(float64(config.Window) * float64(config.Burst) / float64(config.Limit))
+		}
+		err := config.Store.Update(c.Request.Context(), config.Prefix+config.Key(c), ttl, func(state *RateLimitState) {
+			if config.Algorithm == SlidingWindow {
+				result = config.slidingWindow(state, now)
+			} else {
+				result = config.tokenBucket(state, now)
+			}
+		})
+		if err != nil {
+			return nil
+		}
+
+		limit := config.Limit
+		if config.Algorithm == TokenBucket {
+			limit = config.Burst
+		}
+		c.Response.SetHeader("RateLimit-Limit", strconv.Itoa(limit))
+		c.Response.SetHeader("RateLimit-Remaining", strconv.Itoa(result.remaining))
+		c.Response.SetHeader("RateLimit-Reset", strconv.Itoa(seconds(result.reset)))
+		c.Response.SetHeader("RateLimit-Policy", policy)
+		if !result.allowed {
+			c.Response.SetHeader("Retry-After", strconv.Itoa(seconds(result.retryAfter)))
+			return &Error{StatusCode: http.StatusTooManyRequests, Err: ErrRateLimited}
+		}
+		return nil
+	}
+}
+
+type rateLimitResult struct {
+	allowed    bool
+	remaining  int
+	reset      time.Duration
+	retryAfter time.Duration
+}
+
+func (config *RateLimitConfig) tokenBucket(state *RateLimitState, now time.Time) (result rateLimitResult) {
+	rate := float64(config.Limit) / config.Window.Seconds()
+	burst := float64(config.Burst)
+	tokens := burst
+	if !state.Time.IsZero() {
+		tokens = min(burst, state.Value+now.Sub(state.Time).Seconds()*rate)
+	}
+	if tokens >= 1 {
+		tokens--
+		result.allowed = true
+	} else {
+		result.retryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+	state.Value, state.Time = tokens, now
+	result.remaining = int(tokens)
+	result.reset = time.Duration((burst - tokens) / rate * float64(time.Second))
+	return result
+}
+
+func (config *RateLimitConfig) slidingWindow(state *RateLimitState, now time.Time) (result rateLimitResult) {
+	window := config.Window
+	start := now.Truncate(window)
+	switch {
+	case state.Time.Equal(start):
+	case state.Time.Add(window).Equal(start):
+		state.Previous, state.Value = state.Value, 0
+	default:
+		state.Previous, state.Value = 0, 0
+	}
+	state.Time = start
+
+	elapsed := now.Sub(start)
+	limit := float64(config.Limit)
+	weight := 1 - float64(elapsed)/float64(window)
+	count := state.Previous*weight + state.Value
+	if count+1 <= limit {
+		state.Value++
+		count++
+		result.allowed = true
+	} else if free := limit - 1 - state.Value; free < 0 || state.Previous == 0 {
+		result.retryAfter = window - elapsed
+	} else {
+		// The weight of the previous window must drop to free/Previous.
+		result.retryAfter = time.Duration(float64(window)*(1-free/state.Previous)) - elapsed
+	}
+	result.remaining = max(0, int(limit-count))
+	result.reset = window - elapsed
+	return result
+}
+
+// seconds rounds d up to whole seconds, as the rate limit headers expect.
+func seconds(d time.Duration) int {
+	return max(0, int(math.Ceil(d.Seconds())))
+}
+
+// rateLimitShards is the number of shards of a MemoryRateLimitStore.
+const rateLimitShards = 32
+
+// rateLimitSweepInterval is how often a shard removes its expired keys.
+const rateLimitSweepInterval = time.Minute
+
+// MemoryRateLimitStore is a RateLimitStore keeping the states in memory,
+// split in shards to reduce lock contention. Expired keys are removed as
+// the store is used.
+type MemoryRateLimitStore struct {
+	seed   maphash.Seed
+	shards [rateLimitShards]rateLimitShard
+}
+
+type rateLimitShard struct {
+	mu        sync.Mutex
+	entries   map[string]*rateLimitEntry
+	nextSweep time.Time
+}
+
+type rateLimitEntry struct {
+	state   RateLimitState
+	expires time.Time
+}
+
+// NewMemoryRateLimitStore returns an empty MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	s := &MemoryRateLimitStore{seed: maphash.MakeSeed()}
+	for i := range s.shards {
+		s.shards[i].entries = map[string]*rateLimitEntry{}
+	}
+	return s
+}
+
+func (s *MemoryRateLimitStore) Update(_ context.Context, key string, ttl time.Duration, fn func(state *RateLimitState)) error {
+	shard := &s.shards[maphash.String(s.seed, key)%rateLimitShards]
+	now := time.Now()
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if now.After(shard.nextSweep) {
+		shard.sweep(now)
+	}
+	entry, ok := shard.entries[key]
+	if !ok || now.After(entry.expires) {
+		entry = new(rateLimitEntry)
+		shard.entries[key] = entry
+	}
+	fn(&entry.state)
+	entry.expires = now.Add(ttl)
+	return nil
+}
+
+// Len returns the number of keys stored, expired ones included.
+func (s *MemoryRateLimitStore) Len() int {
+	n := 0
+	for i := range s.shards {
+		s.shards[i].mu.Lock()
+		n += len(s.shards[i].entries)
+		s.shards[i].mu.Unlock()
+	}
+	return n
+}
+
+func (shard *rateLimitShard) sweep(now time.Time) {
+	for key, entry := range shard.entries {
+		if now.After(entry.expires) {
+			delete(shard.entries, key)
+		}
+	}
+	shard.nextSweep = now.Add(rateLimitSweepInterval)
+}
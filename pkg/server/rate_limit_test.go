@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/i9si-sistemas/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	server := New(0)
+	server.Get("/search", RateLimit(RateLimitConfig{Limit: 2, Window: time.Hour}), func(c *Context) error {
+		return c.SendString("results")
+	})
+	api := server.Group("/api", RateLimit(RateLimitConfig{
+		Algorithm: SlidingWindow,
+		Limit:     1,
+		Key:       KeyByAPIKey,
+	}))
+	api.Get("/orders", func(c *Context) error {
+		return c.SendString("orders")
+	})
+	server.Get("/free", func(c *Context) error {
+		return c.SendString("free")
+	})
+	request := func(path string, configure ...func(r *http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "203.0.113.9:1234"
+		for _, fn := range configure {
+			fn(req)
+		}
+		return server.Test().Request(req)
+	}
+
+	res := request("/search")
+	assert.Equal(t, res.Code, http.StatusOK)
+	assert.Equal(t, res.Header().Get("RateLimit-Limit"), "2")
+	assert.Equal(t, res.Header().Get("RateLimit-Remaining"), "1")
+	assert.Equal(t, res.Header().Get("RateLimit-Reset"), "1800")
+	assert.Equal(t, res.Header().Get("RateLimit-Policy"), "2;w=3600")
+	assert.Equal(t, request("/search").Code, http.StatusOK)
+
+	res = request("/search")
+	assert.Equal(t, res.Code, http.StatusTooManyRequests)
+	assert.Equal(t, res.Body.String(), ErrRateLimited.Error()+"\n")
+	assert.Equal(t, res.Header().Get("RateLimit-Remaining"), "0")
+	assert.Equal(t, res.Header().Get("Retry-After"), "1800")
+	assert.Equal(t, request("/search", func(r *http.Request) { r.RemoteAddr = "198.51.100.1:1" }).Code, http.StatusOK)
+	assert.Equal(t, request("/free").Code, http.StatusOK)
+
+	withKey := func(key string) func(r *http.Request) {
+		return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+key) }
+	}
+	assert.Equal(t, request("/api/orders", withKey("a")).Code, http.StatusOK)
+	res = request("/api/orders", withKey("a"))
+	assert.Equal(t, res.Code, http.StatusTooManyRequests)
+	assert.Equal(t, res.Header().Get("RateLimit-Policy"), "1;w=60")
+	assert.NotEmpty(t, res.Header().Get("Retry-After"))
+	assert.Equal(t, request("/api/orders", withKey("b")).Code, http.StatusOK)
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	c := NewContext(context.Background(), httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	c.Request.HTTP().RemoteAddr = "192.0.2.1:80"
+	assert.Equal(t, KeyByIP(c), "192.0.2.1")
+	assert.Equal(t, KeyByHeader("X-Tenant")(c), "192.0.2.1")
+	assert.Equal(t, KeyByAPIKey(c), "192.0.2.1")
+
+	c.Request.HTTP().Header.Set("X-Tenant", "acme")
+	c.Request.HTTP().Header.Set("X-Api-Key", "k1")
+	assert.Equal(t, KeyByHeader("X-Tenant")(c), "X-Tenant:acme")
+	assert.Equal(t, KeyByAPIKey(c), "key:k1")
+}
+
+func TestTokenBucket(t *testing.T) {
+	config := RateLimitConfig{Limit: 10, Window: 10 * time.Second, Burst: 3}
+	var state RateLimitState
+	now := time.Now()
+	for i := range 3 {
+		result := config.tokenBucket(&state, now)
+		assert.True(t, result.allowed)
+		assert.Equal(t, result.remaining, 2-i)
+	}
+	result := config.tokenBucket(&state, now)
+	assert.False(t, result.allowed)
+	assert.Equal(t, result.retryAfter, time.Second)
+	assert.Equal(t, result.reset, 3*time.Second)
+
+	result = config.tokenBucket(&state, now.Add(1500*time.Millisecond))
+	assert.True(t, result.allowed)
+	assert.Equal(t, result.remaining, 0)
+	result = config.tokenBucket(&state, now.Add(time.Hour))
+	assert.True(t, result.allowed)
+	assert.Equal(t, result.remaining, 2)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	config := RateLimitConfig{Limit: 4, Window: time.Minute}
+	var state RateLimitState
+	start := time.Now().Truncate(time.Minute)
+	for range 4 {
+		assert.True(t, config.slidingWindow(&state, start.Add(50*time.Second)).allowed)
+	}
+	result := config.slidingWindow(&state, start.Add(50*time.Second))
+	assert.False(t, result.allowed)
+	assert.Equal(t, result.retryAfter, 10*time.Second)
+
+	// A quarter into the next window, the previous one still weighs 3 requests.
+	next := start.Add(time.Minute + 15*time.Second)
+	result = config.slidingWindow(&state, next)
+	assert.True(t, result.allowed)
+	assert.Equal(t, result.remaining, 0)
+	result = config.slidingWindow(&state, next)
+	assert.False(t, result.allowed)
+	assert.Equal(t, result.retryAfter, 15*time.Second)
+
+	result = config.slidingWindow(&state, start.Add(10*time.Minute))
+	assert.True(t, result.allowed)
+	assert.Equal(t, result.remaining, 3)
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	ctx := context.Background()
+	increment := func(state *RateLimitState) { state.Value++ }
+	var got float64
+	read := func(state *RateLimitState) { got = state.Value }
+
+	assert.NoError(t, store.Update(ctx, "a", time.Hour, increment))
+	assert.NoError(t, store.Update(ctx, "a", time.Hour, increment))
+	assert.NoError(t, store.Update(ctx, "a", time.Hour, read))
+	assert.Equal(t, got, float64(2))
+
+	assert.NoError(t, store.Update(ctx, "b", time.Nanosecond, increment))
+	time.Sleep(time.Millisecond)
+	assert.NoError(t, store.Update(ctx, "b", time.Hour, read))
+	assert.Equal(t, got, float64(0))
+
+	for i := range store.shards {
+		store.shards[i].sweep(time.Now().Add(2 * time.Hour))
+	}
+	assert.Equal(t, store.Len(), 0)
+}
@@ -89,3 +89,11 @@ func (r *Request) Query(key string) string {
 func (r *Request) Context() context.Context {
 	return r.req.Context()
 }
+
+// SetContext replaces the context of the request. Called from a middleware,
+// the new context is seen by the middlewares and the handler that follow it.
+//
+//	req.SetContext(context.WithValue(req.Context(), key, value))
+func (r *Request) SetContext(ctx context.Context) {
+	r.req = r.req.WithContext(ctx)
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/i9si-sistemas/nine/internal/requestid"
+)
+
+// RequestIDHeader is the default header of the RequestID middleware.
+const RequestIDHeader = requestid.Header
+
+// NewUUIDv7 returns a random version 7 UUID, which sorts by creation time.
+// It is the default generator of the RequestID middleware.
+func NewUUIDv7() string {
+	return requestid.UUIDv7()
+}
+
+// NewULID returns a random ULID, a 26 characters identifier which
+// sorts by creation time.
+func NewULID() string {
+	return requestid.ULID()
+}
+
+// RequestIDConfig configures the RequestID middleware.
+type RequestIDConfig struct {
+	// Header is read from the request and written to the response.
+	// Defaults to RequestIDHeader.
+	Header string
+	// Generator creates the IDs of the requests received without one.
+	// Defaults to NewUUIDv7.
+	Generator func() string
+	// Validate reports whether an ID received from a client is accepted.
+	// Defaults to IDs of up to 128 letters, digits and "-_.:+=/".
+	Validate func(id string) bool
+	// IgnoreIncoming always generates a new ID, for servers exposed
+	// directly to untrusted clients.
+	IgnoreIncoming bool
+}
+
+// RequestID gives every request an ID, read from the request header when
+// the client sent a valid one and generated otherwise. The ID is echoed in
+// the response header and stored in the request context, where it is read
+// by Context.RequestID, by RequestIDLogHandler and by the nine.Client
+// created with that context, which forwards it to the services it calls.
+//
+//	server.Use(i9.RequestID(i9.RequestIDConfig{Generator: i9.NewULID}))
+func RequestID(options ...RequestIDConfig) HandlerWithContext {
+	var config RequestIDConfig
+	if len(options) > 0 {
+		config = options[0]
+	}
+	if config.Header == "" {
+		config.Header = RequestIDHeader
+	}
+	if config.Generator == nil {
+		config.Generator = NewUUIDv7
+	}
+	if config.Validate == nil {
+		config.Validate = requestid.Valid
+	}
+	return func(c *Context) error {
+		var id string
+		if !config.IgnoreIncoming {
+			id = c.Request.Header(config.Header)
+		}
+		if !config.Validate(id) {
+			id = config.Generator()
+		}
+		c.Response.SetHeader(config.Header, id)
+		c.Request.SetContext(ContextWithRequestID(c.Request.Context(), id))
+		return nil
+	}
+}
+
+// RequestID returns the ID given to the request by the RequestID middleware.
+func (c *Context) RequestID() string {
+	return RequestIDFromContext(c.Request.Context())
+}
+
+// ContextWithRequestID returns a copy of ctx carrying the request ID id.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return requestid.NewContext(ctx, id)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, if any.
+func RequestIDFromContext(ctx context.Context) string {
+	return requestid.FromContext(ctx)
+}
+
+// RequestIDLogHandler wraps a slog.Handler to add a "request_id" attribute
+// to the records logged with a context carrying a request ID.
+//
+//	slog.SetDefault(slog.New(i9.RequestIDLogHandler(slog.NewJSONHandler(os.Stdout, nil))))
+//	slog.InfoContext(c.Request.Context(), "order created")
+func RequestIDLogHandler(h slog.Handler) slog.Handler {
+	return &requestIDLogHandler{Handler: h}
+}
+
+type requestIDLogHandler struct {
+	slog.Handler
+}
+
+func (h *requestIDLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestIDFromContext(ctx); id != "" && !hasAttr(r, "request_id") {
+		r = r.Clone()
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *requestIDLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &requestIDLogHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *requestIDLogHandler) WithGroup(name string) slog.Handler {
+	return &requestIDLogHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+func hasAttr(r slog.Record, key string) bool {
+	found := false
+	r.Attrs(func(a slog.Attr) bool {
+		found = a.Key == key
+		return !found
+	})
+	return found
+}
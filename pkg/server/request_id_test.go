@@ -0,0 +1,89 @@
+package server
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/i9si-sistemas/assert"
+	"github.com/i9si-sistemas/nine/internal/json"
+)
+
+func TestRequestID(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	newServer := func(config ...RequestIDConfig) *Server {
+		server := New(0)
+		server.Use(RequestID(config...))
+		server.Get("/", func(c *Context) error {
+			return c.SendString(c.RequestID())
+		})
+		return server
+	}
+	request := func(server *Server, id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if id != "" {
+			req.Header.Set(RequestIDHeader, id)
+		}
+		return server.Test().Request(req)
+	}
+
+	t.Run("Generated", func(t *testing.T) {
+		res := request(newServer(), "")
+		id := res.Header().Get(RequestIDHeader)
+		assert.True(t, uuid.MatchString(id))
+		assert.Equal(t, res.Body.String(), id)
+	})
+
+	t.Run("Incoming", func(t *testing.T) {
+		res := request(newServer(), "upstream-1")
+		assert.Equal(t, res.Header().Get(RequestIDHeader), "upstream-1")
+		assert.Equal(t, res.Body.String(), "upstream-1")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		res := request(newServer(), "<script>")
+		assert.True(t, uuid.MatchString(res.Body.String()))
+	})
+
+	t.Run("Config", func(t *testing.T) {
+		server := newServer(RequestIDConfig{
+			Header:         "X-Correlation-ID",
+			Generator:      NewULID,
+			IgnoreIncoming: true,
+		})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Correlation-ID", "upstream-1")
+		res := server.Test().Request(req)
+		id := res.Header().Get("X-Correlation-ID")
+		assert.Equal(t, len(id), 26)
+		assert.Equal(t, res.Body.String(), id)
+	})
+}
+
+func TestRequestIDLogHandler(t *testing.T) {
+	out := new(bytes.Buffer)
+	logger := slog.New(RequestIDLogHandler(slog.NewJSONHandler(out, nil))).With("service", "orders")
+	server := New(0)
+	server.Use(RequestID())
+	server.Use(AccessLog(AccessLogConfig{Logger: logger}))
+	server.Get("/", func(c *Context) error {
+		logger.InfoContext(c.Request.Context(), "handled")
+		return c.SendStatus(http.StatusNoContent)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "req-7")
+	server.Test().Request(req)
+
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	assert.Equal(t, len(lines), 2)
+	for _, line := range lines {
+		var record JSON
+		assert.NoError(t, json.Decode(line, &record))
+		assert.Equal(t, record["request_id"], "req-7")
+		assert.Equal(t, record["service"], "orders")
+	}
+	assert.Equal(t, bytes.Count(lines[1], []byte("request_id")), 1)
+}
@@ -4,12 +4,17 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+
+	"github.com/i9si-sistemas/nine/internal/xml"
 )
 
 type Response struct {
 	res        http.ResponseWriter
 	statusCode int
 	sent       bool
+	next       func()
+	// nextHandler is the rest of the middleware chain run by next.
+	nextHandler http.Handler
 }
 
 const DefaultStatusCode = http.StatusOK
@@ -82,6 +87,51 @@ func (r *Response) JSON(data any) error {
 	})
 }
 
+// XMLOptions configures how Response.XML renders a value.
+type XMLOptions struct {
+	// Declaration writes the <?xml ...?> header before the document.
+	Declaration bool
+	// Root names the element that wraps maps and slices. Defaults to "root".
+	Root string
+	// Indent, when not empty, pretty-prints the document using it as the indent unit.
+	Indent string
+}
+
+// XML sends an XML response with the `application/xml` content type.
+//
+// Structs are encoded with encoding/xml and keep their xml tags. Maps such as
+// JSON are converted with the following mapping, which mirrors the one used to
+// decode XML error payloads:
+//
+//   - the map becomes the root element (see XMLOptions.Root);
+//   - the "#text" key becomes the element text;
+//   - keys prefixed with "@" become attributes;
+//   - slices become repeated elements with the key's name.
+//
+// Example:
+//
+//	res.XML(JSON{
+//		"@id":  1,
+//		"name": "gopher",
+//		"tags": []string{"go", "http"},
+//	}, XMLOptions{Root: "user", Declaration: true})
+//	// <?xml version="1.0" encoding="UTF-8"?>
+//	// <user id="1"><name>gopher</name><tags>go</tags><tags>http</tags></user>
+func (r *Response) XML(data any, opts ...XMLOptions) error {
+	var options XMLOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	b, err := xml.Marshal(data, options.Root, options.Indent)
+	if err != nil {
+		return err
+	}
+	if options.Declaration {
+		b = append([]byte(xml.Header), b...)
+	}
+	return r.send("application/xml; charset=utf-8", b)
+}
+
 // SendStatus sends the HTTP response with the specified status code.
 func (r *Response) SendStatus(statusCode int) error {
 	return r.write(func() error {
@@ -93,6 +143,20 @@ func (r *Response) SendStatus(statusCode int) error {
 	})
 }
 
+// send writes b as the body using the given content type
+// instead of sniffing it like Send does.
+func (r *Response) send(contentType string, b []byte) error {
+	return r.write(func() error {
+		r.SetHeader("Content-Type", contentType)
+		if r.invalidStatusCode() {
+			r.statusCode = DefaultStatusCode
+		}
+		r.res.WriteHeader(r.statusCode)
+		_, err := r.res.Write(b)
+		return err
+	})
+}
+
 func (r *Response) write(fn func() error) error {
 	if !r.sent {
 		r.sent = true
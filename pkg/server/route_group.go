@@ -81,6 +81,13 @@ func (g *RouteGroup) Delete(path string, handlers ...any) error {
 	return g.server.Delete(g.fullPath(path), handlers...)
 }
 
+// WebSocket registers a WebSocket endpoint within the group.
+// The group's middlewares run before the upgrade.
+func (g *RouteGroup) WebSocket(path string, handlers ...any) error {
+	handlers = g.routeHandlers(handlers...)
+	return g.server.WebSocket(g.fullPath(path), handlers...)
+}
+
 // fullPath combines the group's base path with the provided path
 func (g *RouteGroup) fullPath(path string) string {
 	if path == "/" || path == "" {
@@ -97,3 +104,10 @@ func (g *RouteGroup) fullPath(path string) string {
 func (g *RouteGroup) routeHandlers(handlers ...any) []any {
 	return append(g.middlewares, handlers...)
 }
+
+// enableCors registers the CORS handlers of the routes of the group.
+func (g *RouteGroup) enableCors(basePath string, route corsRoute) {
+	if enabler, ok := g.server.(corsEnabler); ok {
+		enabler.enableCors(g.fullPath(basePath), route)
+	}
+}
@@ -0,0 +1,285 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Sources of a Content-Security-Policy directive.
+const (
+	CSPSelf          = "'self'"
+	CSPNone          = "'none'"
+	CSPUnsafeInline  = "'unsafe-inline'"
+	CSPStrictDynamic = "'strict-dynamic'"
+	// CSPNonce stands for the nonce of each request, 'nonce-<value>',
+	// which Context.CSPNonce returns for the inline scripts and styles.
+	CSPNonce = "'nonce'"
+)
+
+// OmitHeader removes a header of the SecurityHeaders middleware.
+const OmitHeader = "-"
+
+// CSP builds a Content-Security-Policy, keeping its directives in the
+// order they were first set.
+//
+//	policy := i9.NewCSP().
+//		Set("default-src", i9.CSPSelf).
+//		Set("script-src", i9.CSPSelf, i9.CSPNonce).
+//		Set("img-src", i9.CSPSelf, "https://images.example.com")
+type CSP struct {
+	directives []cspDirective
+	reportOnly bool
+}
+
+type cspDirective struct {
+	name    string
+	sources []string
+}
+
+// NewCSP returns an empty policy.
+func NewCSP() *CSP {
+	return &CSP{}
+}
+
+// DefaultCSP returns the policy the SecurityHeaders middleware defaults to,
+// which only allows resources from the same origin, except for styles,
+// fonts and images, and forbids plugins and framing by other sites.
+func DefaultCSP() *CSP {
+	return NewCSP().
+		Set("default-src", CSPSelf).
+		Set("base-uri", CSPSelf).
+		Set("font-src", CSPSelf, "https:", "data:").
+		Set("form-action", CSPSelf).
+		Set("frame-ancestors", CSPSelf).
+		Set("img-src", CSPSelf, "data:").
+		Set("object-src", CSPNone).
+		Set("script-src", CSPSelf).
+		Set("script-src-attr", CSPNone).
+		Set("style-src", CSPSelf, "https:", CSPUnsafeInline).
+		Set("upgrade-insecure-requests")
+}
+
+// Set sets the sources of a directive, replacing the previous ones.
+func (p *CSP) Set(directive string, sources ...string) *CSP {
+	directive = strings.ToLower(directive)
+	for i := range p.directives {
+		if p.directives[i].name == directive {
+			p.directives[i].sources = sources
+			return p
+		}
+	}
+	p.directives = append(p.directives, cspDirective{directive, sources})
+	return p
+}
+
+// Add adds sources to a directive, setting it when missing.
+func (p *CSP) Add(directive string, sources ...string) *CSP {
+	directive = strings.ToLower(directive)
+	for i := range p.directives {
+		if p.directives[i].name == directive {
+			for _, source := range sources {
+				if !slices.Contains(p.directives[i].sources, source) {
+					p.directives[i].sources = append(p.directives[i].sources, source)
+				}
+			}
+			return p
+		}
+	}
+	return p.Set(directive, sources...)
+}
+
+// Remove removes a directive.
+func (p *CSP) Remove(directive string) *CSP {
+	directive = strings.ToLower(directive)
+	p.directives = slices.DeleteFunc(p.directives, func(d cspDirective) bool {
+		return d.name == directive
+	})
+	return p
+}
+
+// ReportOnly sends the policy as Content-Security-Policy-Report-Only,
+// so browsers report the violations without blocking anything.
+func (p *CSP) ReportOnly() *CSP {
+	p.reportOnly = true
+	return p
+}
+
+// String returns the value of the header, with the CSPNonce placeholder.
+func (p *CSP) String() string {
+	directives := make([]string, len(p.directives))
+	for i, d := range p.directives {
+		directives[i] = strings.Join(append([]string{d.name}, d.sources...), " ")
+	}
+	return strings.Join(directives, "; ")
+}
+
+func (p *CSP) header() string {
+	if p.reportOnly {
+		return "Content-Security-Policy-Report-Only"
+	}
+	return "Content-Security-Policy"
+}
+
+// SecurityHeadersConfig configures the SecurityHeaders middleware. Empty
+// fields keep their default or, in a route group, the value of the
+// SecurityHeaders middleware applied before, and OmitHeader removes the
+// header.
+type SecurityHeadersConfig struct {
+	// ContentSecurityPolicy defaults to DefaultCSP,
+	// an empty policy removes the header.
+	ContentSecurityPolicy *CSP
+	// ReferrerPolicy defaults to "no-referrer".
+	ReferrerPolicy string
+	// PermissionsPolicy, as "camera=(), geolocation=(self)",
+	// is not sent by default.
+	PermissionsPolicy string
+	// CrossOriginOpenerPolicy defaults to "same-origin".
+	CrossOriginOpenerPolicy string
+	// CrossOriginEmbedderPolicy, as "require-corp", is not sent by default.
+	CrossOriginEmbedderPolicy string
+	// CrossOriginResourcePolicy defaults to "same-origin".
+	CrossOriginResourcePolicy string
+	// FrameOptions, the X-Frame-Options header, defaults to "SAMEORIGIN".
+	FrameOptions string
+	// ContentTypeOptions, the X-Content-Type-Options header,
+	// defaults to "nosniff".
+	ContentTypeOptions string
+}
+
+// securityHeaders holds the configuration of a SecurityHeaders middleware
+// run on a request. The ones of route groups run before the global ones,
+// which they override.
+type securityHeaders struct {
+	policy, policyHeader string
+	headers              map[string]string
+	global               bool
+}
+
+type securityHeadersKey struct{}
+
+type cspNonceKey struct{}
+
+// SecurityHeaders sets headers which protect browsers against cross-site
+// scripting, clickjacking and cross-origin leaks. When the
+// Content-Security-Policy holds CSPNonce, each request gets a nonce,
+// returned by Context.CSPNonce. Added to a route group, its configuration
+// overrides the one of the server. Combine it with HSTS for HTTPS sites.
+//
+//	server.Use(i9.SecurityHeaders(i9.SecurityHeadersConfig{
+//		ContentSecurityPolicy: i9.DefaultCSP().Set("script-src", i9.CSPSelf, i9.CSPNonce),
+//	}))
+//	embeds := server.Group("/embed", i9.SecurityHeaders(i9.SecurityHeadersConfig{
+//		FrameOptions:            i9.OmitHeader,
+//		CrossOriginOpenerPolicy: "unsafe-none",
+//	}))
+func SecurityHeaders(options ...SecurityHeadersConfig) HandlerWithContext {
+	var config SecurityHeadersConfig
+	if len(options) > 0 {
+		config = options[0]
+	}
+	policy, policyHeader := "", ""
+	if config.ContentSecurityPolicy != nil {
+		policy, policyHeader = config.ContentSecurityPolicy.String(), config.ContentSecurityPolicy.header()
+	}
+	overrides := map[string]string{}
+	for name, value := range map[string]string{
+		"Referrer-Policy":              config.ReferrerPolicy,
+		"Permissions-Policy":           config.PermissionsPolicy,
+		"Cross-Origin-Opener-Policy":   config.CrossOriginOpenerPolicy,
+		"Cross-Origin-Embedder-Policy": config.CrossOriginEmbedderPolicy,
+		"Cross-Origin-Resource-Policy": config.CrossOriginResourcePolicy,
+		"X-Frame-Options":              config.FrameOptions,
+		"X-Content-Type-Options":       config.ContentTypeOptions,
+	} {
+		if value != "" {
+			overrides[name] = value
+		}
+	}
+
+	return func(c *Context) error {
+		ctx := c.Request.Context()
+		layers, _ := ctx.Value(securityHeadersKey{}).([]securityHeaders)
+		layers = append(slices.Clip(layers), securityHeaders{
+			policy:       policy,
+			policyHeader: policyHeader,
+			headers:      overrides,
+			global:       inGlobalMiddlewares(ctx),
+		})
+		settings := securityHeaders{
+			policy:       defaultPolicy,
+			policyHeader: "Content-Security-Policy",
+			headers: map[string]string{
+				"Referrer-Policy":              "no-referrer",
+				"Cross-Origin-Opener-Policy":   "same-origin",
+				"Cross-Origin-Resource-Policy": "same-origin",
+				"X-Frame-Options":              "SAMEORIGIN",
+				"X-Content-Type-Options":       "nosniff",
+			},
+		}
+		for _, global := range []bool{true, false} {
+			for _, layer := range layers {
+				if layer.global != global {
+					continue
+				}
+				if layer.policyHeader != "" {
+					settings.policy, settings.policyHeader = layer.policy, layer.policyHeader
+				}
+				maps.Copy(settings.headers, layer.headers)
+			}
+		}
+		h := c.Response.HTTP().Header()
+		for name, value := range settings.headers {
+			if value == OmitHeader {
+				h.Del(name)
+			} else {
+				h.Set(name, value)
+			}
+		}
+
+		h.Del("Content-Security-Policy")
+		h.Del("Content-Security-Policy-Report-Only")
+		if policy := settings.policy; policy != "" {
+			if strings.Contains(policy, CSPNonce) {
+				nonce, ok := ctx.Value(cspNonceKey{}).(string)
+				if !ok {
+					nonce = newCSPNonce()
+					ctx = context.WithValue(ctx, cspNonceKey{}, nonce)
+				}
+				policy = strings.ReplaceAll(policy, CSPNonce, "'nonce-"+nonce+"'")
+			}
+			h.Set(settings.policyHeader, policy)
+		}
+		c.Request.SetContext(context.WithValue(ctx, securityHeadersKey{}, layers))
+		return nil
+	}
+}
+
+var defaultPolicy = DefaultCSP().String()
+
+func newCSPNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// CSPNonce returns the nonce of the request in the Content-Security-Policy
+// set by the SecurityHeaders middleware, for the nonce attribute of inline
+// scripts and styles, or "" when the policy holds no CSPNonce.
+//
+//	return c.Render("index", i9.JSON{"nonce": c.CSPNonce()})
+//
+//	<script nonce="{{.nonce}}">...</script>
+func (c *Context) CSPNonce() string {
+	return CSPNonceFromContext(c.Request.Context())
+}
+
+// CSPNonceFromContext returns the nonce stored in ctx by the
+// SecurityHeaders middleware, or "".
+func CSPNonceFromContext(ctx context.Context) string {
+	nonce, _ := ctx.Value(cspNonceKey{}).(string)
+	return nonce
+}
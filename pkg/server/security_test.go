@@ -0,0 +1,107 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/i9si-sistemas/assert"
+)
+
+func TestCSP(t *testing.T) {
+	policy := NewCSP().
+		Set("default-src", CSPSelf).
+		Set("Script-Src", CSPSelf).
+		Add("script-src", CSPNonce, CSPSelf).
+		Add("img-src", "data:").
+		Set("object-src", CSPNone).
+		Remove("object-src")
+	assert.Equal(t, policy.String(), "default-src 'self'; script-src 'self' 'nonce'; img-src data:")
+	assert.Equal(t, policy.header(), "Content-Security-Policy")
+	assert.Equal(t, policy.ReportOnly().header(), "Content-Security-Policy-Report-Only")
+	assert.Equal(t, NewCSP().String(), "")
+	assert.True(t, strings.HasPrefix(DefaultCSP().String(), "default-src 'self'; base-uri 'self';"))
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	server := New(0)
+	server.Use(SecurityHeaders(SecurityHeadersConfig{
+		ContentSecurityPolicy: DefaultCSP().Set("script-src", CSPSelf, CSPNonce),
+		PermissionsPolicy:     "camera=()",
+	}))
+	server.Get("/", func(c *Context) error {
+		return c.SendString(c.CSPNonce())
+	})
+	embed := server.Group("/embed", SecurityHeaders(SecurityHeadersConfig{
+		FrameOptions:              OmitHeader,
+		CrossOriginOpenerPolicy:   "unsafe-none",
+		CrossOriginEmbedderPolicy: "require-corp",
+	}))
+	embed.Get("/widget", func(c *Context) error {
+		return c.SendString(c.CSPNonce())
+	})
+	legacy := server.Group("/legacy", SecurityHeaders(SecurityHeadersConfig{
+		ContentSecurityPolicy: NewCSP(),
+	}))
+	legacy.Get("/", func(c *Context) error {
+		return c.SendString(c.CSPNonce())
+	})
+	request := func(path string) *httptest.ResponseRecorder {
+		return server.Test().Request(httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	res := request("/")
+	h := res.Header()
+	nonce := res.Body.String()
+	assert.NotEmpty(t, nonce)
+	assert.True(t, strings.Contains(h.Get("Content-Security-Policy"), "script-src 'self' 'nonce-"+nonce+"';"))
+	assert.Equal(t, h.Get("Referrer-Policy"), "no-referrer")
+	assert.Equal(t, h.Get("Permissions-Policy"), "camera=()")
+	assert.Equal(t, h.Get("Cross-Origin-Opener-Policy"), "same-origin")
+	assert.Equal(t, h.Get("Cross-Origin-Resource-Policy"), "same-origin")
+	assert.Empty(t, h.Get("Cross-Origin-Embedder-Policy"))
+	assert.Equal(t, h.Get("X-Frame-Options"), "SAMEORIGIN")
+	assert.Equal(t, h.Get("X-Content-Type-Options"), "nosniff")
+	assert.NotEqual(t, request("/").Body.String(), nonce)
+
+	res = request("/embed/widget")
+	h = res.Header()
+	assert.True(t, strings.Contains(h.Get("Content-Security-Policy"), "'nonce-"+res.Body.String()+"'"))
+	assert.Empty(t, h.Get("X-Frame-Options"))
+	assert.Equal(t, h.Get("Cross-Origin-Opener-Policy"), "unsafe-none")
+	assert.Equal(t, h.Get("Cross-Origin-Embedder-Policy"), "require-corp")
+	assert.Equal(t, h.Get("Permissions-Policy"), "camera=()")
+
+	res = request("/legacy")
+	assert.Empty(t, res.Header().Get("Content-Security-Policy"))
+	assert.Equal(t, res.Header().Get("X-Frame-Options"), "SAMEORIGIN")
+}
+
+func TestSecurityHeadersServeFiles(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "index.html"), []byte("<h1>nine</h1>"), 0o644))
+	server := New(0)
+	server.ServeFiles("/", dir)
+	res := server.Test().Request(httptest.NewRequest(http.MethodGet, "/index.html", nil))
+	assert.Equal(t, res.Header().Get("X-Frame-Options"), "DENY")
+	assert.Equal(t, res.Header().Get("X-Content-Type-Options"), "nosniff")
+	assert.Equal(t, res.Header().Get("X-XSS-Protection"), "1; mode=block")
+
+	server = New(0)
+	server.Use(SecurityHeaders())
+	server.ServeFiles("/", dir)
+	res = server.Test().Request(httptest.NewRequest(http.MethodGet, "/index.html", nil))
+	assert.Equal(t, res.Header().Get("X-Frame-Options"), "SAMEORIGIN")
+	assert.NotEmpty(t, res.Header().Get("Content-Security-Policy"))
+	assert.Empty(t, res.Header().Get("X-XSS-Protection"))
+
+	server = New(0)
+	server.Use(SecurityHeaders(SecurityHeadersConfig{FrameOptions: OmitHeader}))
+	server.ServeFiles("/", dir)
+	res = server.Test().Request(httptest.NewRequest(http.MethodGet, "/index.html", nil))
+	assert.Empty(t, res.Header().Get("X-Frame-Options"))
+	assert.Equal(t, res.Header().Get("X-Content-Type-Options"), "nosniff")
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SendFileOptions configures Context.SendFile and Context.SendReader.
+type SendFileOptions struct {
+	// Attachment asks the browser to download the content instead of
+	// displaying it, through `Content-Disposition: attachment`.
+	Attachment bool
+	// Filename is the name suggested for the download.
+	// Defaults to the base name of the file.
+	Filename string
+}
+
+// SendFile streams the file at filePath as the response body.
+//
+// The file is never loaded into memory: byte ranges, conditional requests
+// (If-Modified-Since, If-None-Match, If-Range...) and HEAD requests are
+// handled like http.ServeContent does. The Content-Type is deduced from the
+// file extension and a weak ETag is derived from the size and modification time.
+//
+//	server.Get("/exports/:id", func(c *i9.Context) error {
+//		return c.SendFile("./exports/"+c.Param("id")+".csv", i9.SendFileOptions{Attachment: true})
+//	})
+func (c *Context) SendFile(filePath string, opts ...SendFileOptions) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fileError(err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fileError(fs.ErrNotExist)
+	}
+	if c.Response.HTTP().Header().Get("ETag") == "" {
+		c.Response.SetHeader("ETag", weakETag(info.Size(), info.ModTime()))
+	}
+	return c.SendReader(info.Name(), info.ModTime(), file, opts...)
+}
+
+// SendReader streams content as the response body with the same semantics
+// as SendFile. The name is used to deduce the Content-Type and as the
+// default download filename; modtime sets Last-Modified when not zero.
+func (c *Context) SendReader(name string, modtime time.Time, content io.ReadSeeker, opts ...SendFileOptions) error {
+	var options SendFileOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.Attachment {
+		filename := options.Filename
+		if filename == "" {
+			filename = name
+		}
+		c.Response.SetHeader("Content-Disposition", contentDisposition(filename))
+	}
+	return c.Response.write(func() error {
+		http.ServeContent(c.Response.HTTP(), c.Request.HTTP(), name, modtime, content)
+		return nil
+	})
+}
+
+// contentDisposition builds an attachment header with a filename that can not
+// escape the download directory nor inject header parameters.
+func contentDisposition(filename string) string {
+	filename = filepath.Base(strings.ReplaceAll(filename, "\\", "/"))
+	filename = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' || r == '/' {
+			return -1
+		}
+		return r
+	}, filename)
+	if filename == "" || filename == "." || filename == ".." {
+		return "attachment"
+	}
+	if disposition := mime.FormatMediaType("attachment", map[string]string{"filename": filename}); disposition != "" {
+		return disposition
+	}
+	return "attachment"
+}
+
+func weakETag(size int64, modtime time.Time) string {
+	return fmt.Sprintf(`W/"%x-%x"`, size, modtime.UnixNano())
+}
+
+func fileError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		code := http.StatusNotFound
+		return &Error{StatusCode: code, Err: errors.New(http.StatusText(code))}
+	}
+	return err
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/i9si-sistemas/assert"
+)
+
+func TestSendFileStreaming(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "export.csv")
+	content := "id,name\n1,gopher\n"
+	assert.NoError(t, os.WriteFile(filePath, []byte(content), 0644))
+	modtime := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, os.Chtimes(filePath, modtime, modtime))
+
+	server := New(0)
+	server.Get("/export", func(c *Context) error {
+		return c.SendFile(filePath)
+	})
+	server.Get("/download", func(c *Context) error {
+		return c.SendFile(filePath, SendFileOptions{Attachment: true, Filename: "../../etc/\"passwd\".csv"})
+	})
+	server.Get("/missing", func(c *Context) error {
+		return c.SendFile(filepath.Join(dir, "missing.csv"))
+	})
+	server.Get("/reader", func(c *Context) error {
+		return c.SendReader("relatório.txt", time.Time{}, strings.NewReader("hello world"), SendFileOptions{Attachment: true})
+	})
+
+	t.Run("Full", func(t *testing.T) {
+		res := server.Test().Request(httptest.NewRequest(http.MethodGet, "/export", nil))
+		assert.Equal(t, res.Code, http.StatusOK)
+		assert.Equal(t, res.Body.String(), content)
+		assert.Equal(t, res.Header().Get("Content-Type"), "text/csv; charset=utf-8")
+		assert.Equal(t, res.Header().Get("Last-Modified"), modtime.Format(http.TimeFormat))
+		assert.NotEmpty(t, res.Header().Get("ETag"))
+	})
+
+	t.Run("Range", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/export", nil)
+		req.Header.Set("Range", "bytes=8-")
+		res := server.Test().Request(req)
+		assert.Equal(t, res.Code, http.StatusPartialContent)
+		assert.Equal(t, res.Body.String(), "1,gopher\n")
+		assert.Equal(t, res.Header().Get("Content-Range"), "bytes 8-16/17")
+	})
+
+	t.Run("Conditional", func(t *testing.T) {
+		res := server.Test().Request(httptest.NewRequest(http.MethodGet, "/export", nil))
+		req := httptest.NewRequest(http.MethodGet, "/export", nil)
+		req.Header.Set("If-None-Match", res.Header().Get("ETag"))
+		res = server.Test().Request(req)
+		assert.Equal(t, res.Code, http.StatusNotModified)
+
+		req = httptest.NewRequest(http.MethodGet, "/export", nil)
+		req.Header.Set("If-Modified-Since", modtime.Add(time.Hour).Format(http.TimeFormat))
+		res = server.Test().Request(req)
+		assert.Equal(t, res.Code, http.StatusNotModified)
+	})
+
+	t.Run("Attachment", func(t *testing.T) {
+		res := server.Test().Request(httptest.NewRequest(http.MethodGet, "/download", nil))
+		assert.Equal(t, res.Header().Get("Content-Disposition"), `attachment; filename=passwd.csv`)
+
+		res = server.Test().Request(httptest.NewRequest(http.MethodGet, "/reader", nil))
+		assert.Equal(t, res.Body.String(), "hello world")
+		assert.Equal(t, res.Header().Get("Content-Type"), "text/plain; charset=utf-8")
+		assert.Equal(t, res.Header().Get("Content-Disposition"), `attachment; filename*=utf-8''relat%C3%B3rio.txt`)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		res := server.Test().Request(httptest.NewRequest(http.MethodGet, "/missing", nil))
+		assert.Equal(t, res.Code, http.StatusNotFound)
+	})
+}
@@ -1,8 +1,10 @@
 package server
 
 import (
-	"compress/gzip"
+	"cmp"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
@@ -12,9 +14,14 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"os"
 	"regexp"
 	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/i9si-sistemas/stringx"
 )
@@ -29,10 +36,32 @@ type Server struct {
 	httpServer        *http.Server
 	routes            Routes
 	globalMiddlewares []Handler
+	outerMiddlewares  []Handler
 	addr, port        string
-	corsEnabled       bool
-	corsHandler       HandlerWithContext
+	corsRoutes        map[string]corsRoute
 	listenFn          func() error
+	views             ViewEngine
+	trustedProxies    []netip.Prefix
+	proxyProtocol     *ProxyProtocolConfig
+
+	mu        sync.RWMutex
+	boundAddr net.Addr
+	ready     chan struct{}
+	readyOnce sync.Once
+
+	startupHooks, shutdownHooks []Hook
+	shutdownOnce                sync.Once
+	draining                    atomic.Bool
+	drainPeriod                 time.Duration
+	shutdownTimeout             time.Duration
+	hookTimeout                 time.Duration
+
+	httpsRedirect    *HTTPSRedirectConfig
+	selfSignedTLS    bool
+	redirectServer   *http.Server
+	redirectListener net.Listener
+
+	banner func(address string) string
 }
 
 type Router struct {
@@ -43,8 +72,118 @@ type Router struct {
 }
 
 type ServerOpts struct {
-	Mux      HTTPRequestMultiplexer
+	Mux HTTPRequestMultiplexer
+	// ListenFn replaces Listen. It calls Server.MarkReady once it accepts
+	// requests, so readiness reports the server ready.
 	ListenFn func() error
+	// Views is the engine used by Context.Render.
+	Views ViewEngine
+	// TrustedProxies lists the proxy networks allowed to set forwarding
+	// headers. Context.IP, IPs, Protocol and Hostname ignore those headers
+	// on requests coming from any other address.
+	//
+	//	TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	TrustedProxies []netip.Prefix
+	// ProxyProtocol enables PROXY protocol v1 and v2 headers on the listener,
+	// so the address of the client behind a TCP load balancer is reported
+	// by Request.HTTP().RemoteAddr and Context.IP. Only the connections
+	// from its TrustedSources may send a header.
+	ProxyProtocol *ProxyProtocolConfig
+
+	// ReadHeaderTimeout is the time allowed to read the request headers,
+	// which protects against slowloris attacks. Defaults to
+	// DefaultReadHeaderTimeout, a negative value disables it.
+	ReadHeaderTimeout time.Duration
+	// ReadTimeout is the time allowed to read the whole request, body included.
+	ReadTimeout time.Duration
+	// WriteTimeout is the time allowed to write the response. Server-Sent
+	// Events and WebSocket connections clear it once they start streaming.
+	WriteTimeout time.Duration
+	// IdleTimeout is the time a keep-alive connection waits for the next
+	// request. Defaults to DefaultIdleTimeout, a negative value disables it.
+	IdleTimeout time.Duration
+	// MaxHeaderBytes limits the size of the request headers.
+	// Defaults to DefaultMaxHeaderBytes.
+	MaxHeaderBytes int
+	// ErrorLog logs the errors of connections and handlers that panic.
+	// The standard logger is used when nil.
+	ErrorLog *log.Logger
+	// BaseContext returns the base context of the requests accepted by a listener.
+	BaseContext func(ln net.Listener) context.Context
+	// ConnContext derives the context of the requests of a new connection.
+	// It runs before the next connection is accepted, so with ProxyProtocol
+	// calling c.RemoteAddr waits for the PROXY header.
+	ConnContext func(ctx context.Context, c net.Conn) context.Context
+	// TLSConfig configures ListenTLS. Certificates can be given here
+	// instead of through the certFile and keyFile arguments.
+	TLSConfig *tls.Config
+	// SelfSignedTLS lets ListenTLS serve an in-memory self-signed
+	// certificate for localhost when neither files nor TLSConfig provide
+	// one, which is only suitable for local development.
+	SelfSignedTLS bool
+	// ClientCAs verifies the client certificates of TLS connections
+	// (mutual TLS). See LoadCertPool and the ClientCert middleware.
+	ClientCAs *x509.CertPool
+	// ClientAuth is the client certificate policy. It defaults to
+	// tls.RequireAndVerifyClientCert when ClientCAs is set.
+	ClientAuth tls.ClientAuthType
+	// HTTP2 configures the HTTP/2 connections.
+	HTTP2 *http.HTTP2Config
+	// H2C accepts cleartext HTTP/2 connections with prior knowledge
+	// alongside HTTP/1, for service-to-service traffic behind a proxy.
+	H2C bool
+	// DisableHTTP2 serves only HTTP/1, even over TLS.
+	DisableHTTP2 bool
+	// Banner formats the startup banner logged with the address of the
+	// server once it is listening. Returning an empty string, or setting
+	// DisableBanner, silences it.
+	Banner        func(address string) string
+	DisableBanner bool
+	// HTTPSRedirect starts, along with ListenTLS, a plain HTTP server
+	// redirecting every request to HTTPS. Shutdown stops both servers.
+	HTTPSRedirect *HTTPSRedirectConfig
+
+	// DrainPeriod is the time during which the server keeps serving while
+	// reporting not ready, before shutting down, so load balancers can
+	// remove it first.
+	DrainPeriod time.Duration
+	// ShutdownTimeout bounds the graceful shutdown performed by Run.
+	// Defaults to DefaultShutdownTimeout.
+	ShutdownTimeout time.Duration
+	// HookTimeout bounds each OnStartup and OnShutdown hook.
+	// Defaults to DefaultHookTimeout.
+	HookTimeout time.Duration
+}
+
+// Defaults applied by New to the zero fields of ServerOpts.
+const (
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultIdleTimeout       = 2 * time.Minute
+	DefaultMaxHeaderBytes    = http.DefaultMaxHeaderBytes
+)
+
+// newHTTPServer creates the http.Server configured by opts.
+func newHTTPServer(opts ServerOpts) *http.Server {
+	srv := &http.Server{
+		ReadHeaderTimeout: cmp.Or(opts.ReadHeaderTimeout, DefaultReadHeaderTimeout),
+		ReadTimeout:       opts.ReadTimeout,
+		WriteTimeout:      opts.WriteTimeout,
+		IdleTimeout:       cmp.Or(opts.IdleTimeout, DefaultIdleTimeout),
+		MaxHeaderBytes:    cmp.Or(opts.MaxHeaderBytes, DefaultMaxHeaderBytes),
+		ErrorLog:          opts.ErrorLog,
+		BaseContext:       opts.BaseContext,
+		ConnContext:       opts.ConnContext,
+		TLSConfig:         clientAuthTLSConfig(opts.TLSConfig, opts),
+		HTTP2:             opts.HTTP2,
+	}
+	if opts.H2C || opts.DisableHTTP2 {
+		protocols := new(http.Protocols)
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(!opts.DisableHTTP2)
+		protocols.SetUnencryptedHTTP2(opts.H2C && !opts.DisableHTTP2)
+		srv.Protocols = protocols
+	}
+	return srv
 }
 
 // New creates a new `Server` instance bound to the specified port.
@@ -54,22 +193,63 @@ func New[P int | string](
 	opts ...ServerOpts,
 ) (s *Server) {
 	s = &Server{
-		mux:        http.NewServeMux(),
-		routes:     make([]Router, 0),
-		port:       fmt.Sprint(port),
-		httpServer: new(http.Server),
+		mux:    http.NewServeMux(),
+		routes: make([]Router, 0),
+		port:   fmt.Sprint(port),
+		ready:  make(chan struct{}),
+		banner: banner,
 	}
+	var customOptions ServerOpts
 	if len(opts) > 0 {
-		customOptions := opts[0]
-		s.mux = customOptions.Mux
+		customOptions = opts[0]
+		if customOptions.Mux != nil {
+			s.mux = customOptions.Mux
+		}
 		s.listenFn = customOptions.ListenFn
+		s.views = customOptions.Views
+		s.trustedProxies = customOptions.TrustedProxies
+		s.proxyProtocol = customOptions.ProxyProtocol
+	}
+	s.httpServer = newHTTPServer(customOptions)
+	s.drainPeriod = customOptions.DrainPeriod
+	s.shutdownTimeout = cmp.Or(customOptions.ShutdownTimeout, DefaultShutdownTimeout)
+	s.hookTimeout = cmp.Or(customOptions.HookTimeout, DefaultHookTimeout)
+	s.httpsRedirect = customOptions.HTTPSRedirect
+	s.selfSignedTLS = customOptions.SelfSignedTLS
+	s.banner = banner
+	if customOptions.Banner != nil {
+		s.banner = customOptions.Banner
+	}
+	if customOptions.DisableBanner {
+		s.banner = nil
 	}
 	return
 }
 
+// EnableCors answers the OPTIONS requests of every route with h,
+// unless a route group has its own CORS configuration.
 func (s *Server) EnableCors(h HandlerWithContext) {
-	s.corsEnabled = true
-	s.corsHandler = h
+	s.enableCors("", corsRoute{preflight: h})
+}
+
+func (s *Server) enableCors(basePath string, route corsRoute) {
+	if s.corsRoutes == nil {
+		s.corsRoutes = map[string]corsRoute{}
+	}
+	s.corsRoutes[strings.TrimSuffix(s.transformPath(basePath), "/")] = route
+}
+
+// corsRoute returns the CORS handlers of the innermost base path of an
+// endpoint.
+func (s *Server) corsRoute(endpoint string) (corsRoute, bool) {
+	var route corsRoute
+	found, longest := false, -1
+	for basePath, r := range s.corsRoutes {
+		if len(basePath) > longest && (endpoint == basePath || strings.HasPrefix(endpoint, basePath+"/")) {
+			route, found, longest = r, true, len(basePath)
+		}
+	}
+	return route, found
 }
 
 // ServeFiles serves static files from the specified directory for a given URL pattern.
@@ -120,7 +300,11 @@ func (s *Server) ServeFilesWithFS(pattern string, fs fs.FS) {
 }
 
 func (s *Server) notFoundMiddleware(req *Request, res *Response) error {
-	if exists := s.patternExists(req.Method(), req.Path()); !exists {
+	method := req.Method()
+	// GET routes also answer HEAD requests, as net/http routes them.
+	exists := s.patternExists(method, req.Path()) ||
+		method == http.MethodHead && s.patternExists(http.MethodGet, req.Path())
+	if !exists {
 		code := http.StatusNotFound
 		return &Error{
 			StatusCode: code,
@@ -160,17 +344,23 @@ func patternToRegex(pattern string) string {
 	return "^" + regexPattern + "$"
 }
 
+// Port returns the port the server is listening on. Before the server is
+// bound, it is the configured port, which is empty for a random one.
 func (s *Server) Port() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return s.port
 }
 
 func (s *Server) resetPort() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.port = ""
+	s.boundAddr = nil
 }
 
 func (s *Server) Handler() http.Handler {
 	s.registerRoutes()
-	s.setAddr()
 	return &ServerHandler{s}
 }
 
@@ -179,7 +369,18 @@ type ServerHandler struct {
 }
 
 func (s *ServerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	s.mux.ServeHTTP(w, r)
+	ctx := context.WithValue(r.Context(), serverContextKey{}, s.Server)
+	s.mux.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// serverContextKey stores the *Server handling a request in its context.
+type serverContextKey struct{}
+
+// serverFromRequest returns the *Server handling r, or nil when r
+// was not dispatched through a ServerHandler.
+func serverFromRequest(r *http.Request) *Server {
+	s, _ := r.Context().Value(serverContextKey{}).(*Server)
+	return s
 }
 
 // Listen starts the HTTP server, listening on the configured address, and binds all registered routes and middleware.
@@ -190,33 +391,49 @@ func (s *ServerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 //	}
 //	log.Fatal(server.Listen())
 func (s *Server) Listen() error {
-	return s.listen(s.httpServer.ListenAndServe)
+	return s.listen(s.bindTCP, s.httpServer.Serve, "http")
 }
 
+// ListenTLS starts the HTTPS server. The certificate and key files can be
+// empty when ServerOpts.TLSConfig provides the certificates. Without files
+// nor certificates, it returns ErrNoTLSCertificate, unless
+// ServerOpts.SelfSignedTLS allows a self-signed certificate for localhost.
 func (s *Server) ListenTLS(certFile, keyFile string) error {
-	return s.listen(func() error {
-		return s.httpServer.ListenAndServeTLS(certFile, keyFile)
-	})
+	if err := s.ensureCertificate(certFile, keyFile); err != nil {
+		return err
+	}
+	bind := func() ([]net.Listener, error) {
+		listeners, err := s.bindTCP()
+		if err != nil || s.httpsRedirect == nil {
+			return listeners, err
+		}
+		if err := s.startRedirect(); err != nil {
+			listeners[0].Close()
+			return nil, err
+		}
+		return listeners, nil
+	}
+	err := s.listen(bind, func(ln net.Listener) error {
+		return s.httpServer.ServeTLS(ln, certFile, keyFile)
+	}, "https")
+	if err != nil {
+		s.closeRedirect()
+	}
+	return err
 }
 
-func (s *Server) listen(fn func() error) error {
-	if s.listenFn != nil {
-		return s.listenFn()
+// bindTCP listens on the configured port, or on a random one when it is
+// empty. The port actually bound is reported by Port once listening.
+func (s *Server) bindTCP() ([]net.Listener, error) {
+	s.mu.Lock()
+	s.setAddr()
+	addr := s.addr
+	s.mu.Unlock()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
 	}
-	errCh := make(chan error)
-	server := s.httpServer
-	server.Handler = s.Handler()
-	server.Addr = s.addr
-	go func() {
-		err := fn()
-		if err != nil && err != http.ErrServerClosed {
-			errCh <- err
-			return
-		}
-		errCh <- nil
-	}()
-	log.Println(banner(s.addr))
-	return <-errCh
+	return []net.Listener{ln}, nil
 }
 
 func banner(address string) string {
@@ -228,7 +445,7 @@ func banner(address string) string {
 		"/_/ /_/_/_/ /_/\\___/ ",
 		"                     ",
 	}
-	addressLine := fmt.Sprintf("http://127.0.0.1%s [PID: %d]", address, os.Getpid())
+	addressLine := fmt.Sprintf("%s [PID: %d]", address, os.Getpid())
 	maxLogoWidth := 0
 	for _, line := range logo {
 		if len([]rune(line)) > maxLogoWidth {
@@ -277,85 +494,66 @@ func banner(address string) string {
 }
 
 // Shutdown gracefully stops the HTTP server, allowing any pending requests to complete.
-// This method should be called when you want to stop the server from accepting new connections
-// and shut it down safely without losing any ongoing requests.
-//
-// Example usage:
-//
-//	 srv := nine.NewServer(os.Getenv("PORT"))
-//	 srv.Get("/", func(req *nine.Request, res *nine.Response) error {
-//		return res.Send([]byte("Hello World"))
-//	  })
-//
-//	 stop := make(chan os.Signal, 1)
-//	 signal.Notify(stop, os.Interrupt)
-//	 var wg sync.WaitGroup
-//	 wg.Add(1)
-//
-//		go func() {
-//			defer wg.Done()
-//			<-stop
-//			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-//			defer cancel()
-//			if err := srv.Shutdown(ctx); err != nil {
-//				fmt.Printf("Error shutting down server: %v\n", err)
-//			}
-//			fmt.Println("Server exited gracefully")
-//		}()
-//
-//	 fmt.Println("starting server")
-//
-//	 if err := srv.Listen(); err != nil && err != http.ErrServerClosed {
-//		fmt.Printf("Error starting server: %v\n", err)
-//	 }
+// The server first reports not ready during ServerOpts.DrainPeriod, then stops
+// accepting connections, waits for the active ones and runs the OnShutdown hooks.
+// The HTTPS redirect server is stopped along with it.
+// Run calls it when the process receives SIGINT or SIGTERM.
 //
-//	 wg.Wait()
+//	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//	defer cancel()
+//	if err := srv.Shutdown(ctx); err != nil {
+//		fmt.Printf("Error shutting down server: %v\n", err)
+//	}
 func (s *Server) Shutdown(ctx context.Context) error {
+	s.drain(ctx)
 	s.resetPort()
-	return s.httpServer.Shutdown(ctx)
+	err := errors.Join(s.httpServer.Shutdown(ctx), s.shutdownRedirect(ctx))
+	return errors.Join(err, s.runShutdownHooks(ctx))
 }
 
-func (s *Server) setAddr() error {
-	if len(s.port) == 0 {
-		listener, err := net.Listen("tcp", ":0")
-		if err != nil {
-			return err
-		}
-		defer listener.Close()
-
-		addr := listener.Addr().String()
-		_, port, err := net.SplitHostPort(addr)
-		if err != nil {
-			return err
-		}
-		s.port = port
+// setAddr sets the address to listen on, where port 0 lets the
+// system pick a free port when the server is bound.
+func (s *Server) setAddr() {
+	port := s.port
+	if len(port) == 0 {
+		port = "0"
 	}
-	s.addr = fmt.Sprintf(":%s", s.port)
-	return nil
+	s.addr = fmt.Sprintf(":%s", port)
 }
 
 func (s *Server) registerRoutes() {
-	registredCors := map[string]struct{}{}
+	explicitOptions := map[string]struct{}{}
+	for _, route := range s.routes {
+		if method, endpoint, ok := strings.Cut(route.pattern, " "); ok && method == http.MethodOptions {
+			explicitOptions[endpoint] = struct{}{}
+		}
+	}
 	for _, route := range s.routes {
 		finalHandler := httpHandler(route.handler, route.pattern)
 		if !route.servingFiles {
-			finalHandler = registerMiddlewares(finalHandler, s.notFoundMiddleware)
+			finalHandler = registerMiddlewares(finalHandler, route.pattern, s.notFoundMiddleware)
+		}
+		if len(s.globalMiddlewares) > 0 {
+			finalHandler = markGlobalMiddlewares(registerMiddlewares(finalHandler, route.pattern, s.globalMiddlewares...))
 		}
-		finalHandler = registerMiddlewares(finalHandler, s.globalMiddlewares...)
-		finalHandler = registerMiddlewares(finalHandler, route.middlewares...)
+		finalHandler = registerMiddlewares(finalHandler, route.pattern, route.middlewares...)
+		// CORS runs first, so the responses of the route middlewares get its headers.
+		_, endpoint, hasMethod := strings.Cut(route.pattern, " ")
+		cors, corsEnabled := s.corsRoute(endpoint)
+		if hasMethod && corsEnabled && cors.actual != nil {
+			finalHandler = registerMiddlewares(finalHandler, route.pattern, func(req *Request, res *Response) error {
+				return cors.actual.Handler(req, res)(req, res)
+			})
+		}
+		finalHandler = registerMiddlewares(finalHandler, route.pattern, s.outerMiddlewares...)
 		s.mux.Handle(route.pattern, finalHandler)
-		if s.corsEnabled {
-			parts := stringx.String(route.pattern).SplitN(stringx.Space.String(), 2)
-			if len(parts) < 2 {
-				return
-			}
-			endpoint := parts[1]
-			if _, exists := registredCors[endpoint]; !exists {
-				registredCors[endpoint] = struct{}{}
-				s.mux.Handle(s.routePattern(http.MethodOptions, endpoint), httpHandlerWithContext(s.corsHandler, endpoint))
-			}
+		if !hasMethod || !corsEnabled {
+			continue
+		}
+		if _, exists := explicitOptions[endpoint]; !exists {
+			explicitOptions[endpoint] = struct{}{}
+			s.mux.Handle(s.routePattern(http.MethodOptions, endpoint), httpHandlerWithContext(cors.preflight, endpoint))
 		}
-
 	}
 }
 
@@ -456,6 +654,7 @@ func (s *Server) Delete(endpoint string, handlers ...any) error {
 }
 
 // Use adds a global middleware to the server's middleware stack.
+// Global middlewares run after the middlewares of the route and its group.
 func (s *Server) Use(middlewares ...any) error {
 	for _, middleware := range middlewares {
 		handler, err := validateHandler(middleware)
@@ -467,9 +666,33 @@ func (s *Server) Use(middlewares ...any) error {
 	return nil
 }
 
-func registerMiddlewares(handler http.Handler, middlewares ...Handler) http.Handler {
+// useOuter adds a middleware which wraps every route, outside CORS and the
+// route and group middlewares, so it sees the responses they write.
+func (s *Server) useOuter(middleware HandlerWithContext) {
+	s.outerMiddlewares = append(s.outerMiddlewares, func(req *Request, res *Response) error {
+		return middleware.Handler(req, res)(req, res)
+	})
+}
+
+// globalMiddlewaresKey marks the requests which reached the global middlewares.
+type globalMiddlewaresKey struct{}
+
+func markGlobalMiddlewares(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), globalMiddlewaresKey{}, true)))
+	})
+}
+
+// inGlobalMiddlewares reports whether the route and group middlewares
+// of a request already ran.
+func inGlobalMiddlewares(ctx context.Context) bool {
+	global, _ := ctx.Value(globalMiddlewaresKey{}).(bool)
+	return global
+}
+
+func registerMiddlewares(handler http.Handler, pattern string, middlewares ...Handler) http.Handler {
 	for i := len(middlewares) - 1; i >= 0; i-- {
-		handler = httpMiddleware(middlewares[i], handler)
+		handler = httpMiddleware(middlewares[i], handler, pattern)
 	}
 	return handler
 }
@@ -485,7 +708,8 @@ type TestServer struct {
 	HandlerTester
 }
 
-// Test configures the Server for testing.
+// Test configures the Server for testing and marks it ready, as a
+// listening server.
 //
 //	server := nine.NewServer(8080)
 //	message := "Hello World"
@@ -495,6 +719,7 @@ type TestServer struct {
 //	testServer := server.Test()
 func (s *Server) Test() *TestServer {
 	s.mux = http.NewServeMux()
+	s.markReady()
 	return &TestServer{HandlerTester: s}
 }
 
@@ -517,20 +742,37 @@ func (t *TestServer) Request(r *http.Request) *httptest.ResponseRecorder {
 	return w
 }
 
-func httpMiddleware(m Handler, next http.Handler) http.Handler {
+// httpMiddleware runs m before next. The middleware can run next itself
+// through Context.Next, to act on the response, and the writer and request
+// it sets on its Response and Request are the ones passed to next.
+func httpMiddleware(m Handler, next http.Handler, pattern ...string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		req := NewRequest(r)
+		req := NewRequest(r, pattern...)
 		res := NewResponse(w)
+		res.nextHandler = next
+		// rest records what the rest of the chain wrote once Next ran it.
+		var rest *responseWriter
+		res.next = func() {
+			if rest != nil {
+				return
+			}
+			rest = newResponseWriter(res.HTTP())
+			next.ServeHTTP(rest, req.HTTP())
+		}
 		if err := m(&req, &res); err != nil {
+			if rest != nil && (rest.wroteHeader || res.Sent()) {
+				log.Printf("nine: %s: middleware error after the response was written: %v", r.URL.Path, err)
+				return
+			}
 			if srvErr, ok := err.(*Error); ok && srvErr != nil {
-				srvErr.ServeHTTP(w, r)
+				srvErr.ServeHTTP(res.HTTP(), r)
 				return
 			}
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(res.HTTP(), err.Error(), http.StatusInternalServerError)
 			return
 		}
-		if !res.Sent() {
-			next.ServeHTTP(w, r)
+		if !res.Sent() && rest == nil {
+			res.next()
 		}
 	})
 }
@@ -594,26 +836,19 @@ func ServeFiles(path http.FileSystem) Handler {
 
 		contentType := http.DetectContentType(buffer[:n])
 		req.HTTP().Header.Set("Content-Type", contentType)
-		res.HTTP().Header().Set("X-Content-Type-Options", "nosniff")
-		res.HTTP().Header().Set("X-Frame-Options", "DENY")
-		res.HTTP().Header().Set("X-XSS-Protection", "1; mode=block")
-		if stringx.String(req.Header("Accept-Encoding")).Includes("gzip") {
-			res.HTTP().Header().Set("Content-Encoding", "gzip")
-			gz := gzip.NewWriter(res.HTTP())
-			defer gz.Close()
-			res.ChangeResponseWriter(&gzipResponseWriter{Writer: gz, ResponseWriter: res.HTTP()})
+		// The SecurityHeaders middleware, when it ran, chose these headers.
+		if req.Context().Value(securityHeadersKey{}) == nil {
+			res.HTTP().Header().Set("X-Content-Type-Options", "nosniff")
+			res.HTTP().Header().Set("X-Frame-Options", "DENY")
+			res.HTTP().Header().Set("X-XSS-Protection", "1; mode=block")
 		}
+		w := fileCompression.newWriter(res.HTTP(), req.Header("Accept-Encoding"))
+		res.ChangeResponseWriter(w)
 
 		staticFileSystem.ServeHTTP(res.HTTP(), req.HTTP())
-		return nil
+		return w.Close()
 	}
 }
 
-type gzipResponseWriter struct {
-	io.Writer
-	http.ResponseWriter
-}
-
-func (w *gzipResponseWriter) Write(b []byte) (int, error) {
-	return w.Writer.Write(b)
-}
+// fileCompression compresses every static file of a compressible type.
+var fileCompression = newCompressSettings(CompressConfig{MinSize: -1})
@@ -9,6 +9,7 @@ import (
 	"io"
 	"log"
 	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -407,7 +408,7 @@ func TestSetAddr(t *testing.T) {
 	}
 	server = New("")
 	server.setAddr()
-	expected = ":" + server.port
+	expected = ":0"
 	if server.addr != expected {
 		t.Fatalf("result %s, expected %s", server.addr, expected)
 	}
@@ -556,3 +557,129 @@ func assertEndpoint(t *testing.T, method, endpoint, message string, server *Serv
 		t.Fatalf("result: %s, expected: %s", result, message)
 	}
 }
+
+func TestServerOpts(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		srv := New(0).httpServer
+		assert.Equal(t, srv.ReadHeaderTimeout, DefaultReadHeaderTimeout)
+		assert.Equal(t, srv.IdleTimeout, DefaultIdleTimeout)
+		assert.Equal(t, srv.MaxHeaderBytes, DefaultMaxHeaderBytes)
+		assert.Zero(t, srv.ReadTimeout)
+		assert.Zero(t, srv.WriteTimeout)
+		assert.True(t, srv.Protocols == nil)
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		errorLog := log.New(io.Discard, "", 0)
+		srv := New(0, ServerOpts{
+			ReadHeaderTimeout: -1,
+			ReadTimeout:       time.Second,
+			WriteTimeout:      2 * time.Second,
+			IdleTimeout:       3 * time.Second,
+			MaxHeaderBytes:    4096,
+			ErrorLog:          errorLog,
+			HTTP2:             &http.HTTP2Config{MaxConcurrentStreams: 10},
+			DisableHTTP2:      true,
+		}).httpServer
+		assert.Equal(t, srv.ReadHeaderTimeout, time.Duration(-1))
+		assert.Equal(t, srv.ReadTimeout, time.Second)
+		assert.Equal(t, srv.WriteTimeout, 2*time.Second)
+		assert.Equal(t, srv.IdleTimeout, 3*time.Second)
+		assert.Equal(t, srv.MaxHeaderBytes, 4096)
+		assert.Equal(t, srv.ErrorLog, errorLog)
+		assert.Equal(t, srv.HTTP2.MaxConcurrentStreams, 10)
+		assert.True(t, srv.Protocols.HTTP1())
+		assert.False(t, srv.Protocols.HTTP2())
+	})
+
+	t.Run("BaseContext", func(t *testing.T) {
+		type key struct{}
+		server := New("", ServerOpts{
+			BaseContext: func(net.Listener) context.Context {
+				return context.WithValue(context.Background(), key{}, "base")
+			},
+		})
+		server.Get("/base", func(c *Context) error {
+			return c.SendString(c.Request.Context().Value(key{}).(string))
+		})
+		startServer(t, server, server.Listen)
+		assert.Equal(t, get(t, http.DefaultClient, "http://127.0.0.1:"+server.Port()+"/base"), "base")
+	})
+
+	t.Run("H2C", func(t *testing.T) {
+		server := New("", ServerOpts{H2C: true})
+		server.Get("/proto", func(c *Context) error {
+			return c.SendString(c.Request.HTTP().Proto)
+		})
+		startServer(t, server, server.Listen)
+
+		protocols := new(http.Protocols)
+		protocols.SetUnencryptedHTTP2(true)
+		client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+		assert.Equal(t, get(t, client, "http://127.0.0.1:"+server.Port()+"/proto"), "HTTP/2.0")
+		assert.Equal(t, get(t, http.DefaultClient, "http://127.0.0.1:"+server.Port()+"/proto"), "HTTP/1.1")
+	})
+}
+
+func TestContextNext(t *testing.T) {
+	server := New(0)
+	var order []string
+	server.Use(func(c *Context) error {
+		order = append(order, "global before")
+		w := newResponseWriter(c.Response.HTTP())
+		c.Response.ChangeResponseWriter(w)
+		c.Next()
+		c.Next()
+		order = append(order, fmt.Sprintf("global after %d %d", w.Status(), w.Bytes()))
+		return nil
+	})
+	server.Get("/", func(c *Context) error {
+		order = append(order, "route")
+		return nil
+	}, func(c *Context) error {
+		order = append(order, "handler "+c.PathRegistred())
+		return c.Status(http.StatusAccepted).SendString("done")
+	})
+	server.Get("/denied", func(c *Context) error {
+		return &Error{StatusCode: http.StatusForbidden, Err: errors.New("denied")}
+	}, func(c *Context) error {
+		order = append(order, "unreachable")
+		return nil
+	})
+
+	res := server.Test().Request(httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, res.Code, http.StatusAccepted)
+	assert.Equal(t, order, []string{"route", "global before", "handler GET /", "global after 202 4"})
+
+	order = nil
+	res = server.Test().Request(httptest.NewRequest(http.MethodGet, "/denied", nil))
+	assert.Equal(t, res.Code, http.StatusForbidden)
+	assert.Empty(t, order)
+}
+
+func TestContextNextError(t *testing.T) {
+	server := New(0)
+	server.Use(func(c *Context) error {
+		c.Next()
+		if c.Query("fail") != "" {
+			return &Error{StatusCode: http.StatusBadGateway, Err: errors.New("upstream failed")}
+		}
+		return nil
+	})
+	server.Get("/empty", func(c *Context) error {
+		return nil
+	})
+	server.Get("/written", func(c *Context) error {
+		return c.SendString("written")
+	})
+	request := func(target string) *httptest.ResponseRecorder {
+		return server.Test().Request(httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	res := request("/empty?fail=1")
+	assert.Equal(t, res.Code, http.StatusBadGateway)
+	assert.Equal(t, res.Body.String(), "upstream failed\n")
+	res = request("/written?fail=1")
+	assert.Equal(t, res.Code, http.StatusOK)
+	assert.Equal(t, res.Body.String(), "written")
+}
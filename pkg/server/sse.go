@@ -0,0 +1,275 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/i9si-sistemas/nine/internal/json"
+)
+
+// ErrStreamingUnsupported is returned when the response writer can not be flushed.
+var ErrStreamingUnsupported = errors.New("streaming unsupported")
+
+// ErrInvalidEventField is returned when the name or the id of an event holds
+// a line break, which would let it inject other fields.
+var ErrInvalidEventField = errors.New("event name and id must not contain line breaks")
+
+// SSEOptions configures a Server-Sent Events stream.
+type SSEOptions struct {
+	// KeepAlive is the interval between comment lines written to keep
+	// idle connections open. Zero disables keep-alives.
+	KeepAlive time.Duration
+	// Retry is the reconnection delay sent to the client when the stream opens.
+	Retry time.Duration
+	// History stores the sent events so reconnecting clients can be
+	// replayed everything after their Last-Event-ID.
+	History EventHistory
+}
+
+// Event is a single Server-Sent Event.
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// EventHistory stores events for Last-Event-ID replays.
+// Implementations must be safe for concurrent use.
+type EventHistory interface {
+	// Add stores an event.
+	Add(e Event)
+	// Since returns the events stored after the one with the given id,
+	// oldest first. It returns nil when the id is unknown.
+	Since(id string) []Event
+}
+
+// NewEventHistory returns an in-memory EventHistory that keeps the last size events.
+func NewEventHistory(size int) EventHistory {
+	return &eventHistory{size: max(size, 1)}
+}
+
+type eventHistory struct {
+	mu     sync.Mutex
+	size   int
+	events []Event
+}
+
+func (h *eventHistory) Add(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, e)
+	if len(h.events) > h.size {
+		h.events = h.events[len(h.events)-h.size:]
+	}
+}
+
+func (h *eventHistory) Since(id string) []Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := len(h.events) - 1; i >= 0; i-- {
+		if h.events[i].ID == id {
+			return append([]Event(nil), h.events[i+1:]...)
+		}
+	}
+	return nil
+}
+
+// EventStream writes Server-Sent Events to the client.
+// It is safe for concurrent use.
+type EventStream struct {
+	ctx     context.Context
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	history EventHistory
+}
+
+// Context returns the request context, which is canceled when the client disconnects.
+func (s *EventStream) Context() context.Context {
+	return s.ctx
+}
+
+// Send writes an event. Strings and byte slices are sent as is and any
+// other data is encoded as JSON. Events with an id are stored in the history.
+// The event name and id must not contain line breaks.
+//
+//	stream.Send("progress", "42", nine.JSON{"percent": 42})
+func (s *EventStream) Send(event, id string, data any) error {
+	var payload string
+	switch v := data.(type) {
+	case string:
+		payload = v
+	case []byte:
+		payload = string(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		payload = string(b)
+	}
+	e := Event{ID: id, Event: event, Data: payload}
+	if err := s.writeEvent(e); err != nil {
+		return err
+	}
+	if id != "" && s.history != nil {
+		s.history.Add(e)
+	}
+	return nil
+}
+
+// Retry tells the client how long to wait before reconnecting.
+func (s *EventStream) Retry(d time.Duration) error {
+	return s.writeRaw(fmt.Sprintf("retry: %d\n\n", d.Milliseconds()))
+}
+
+// Comment writes a comment line, which clients ignore.
+func (s *EventStream) Comment(text string) error {
+	buf := new(bytes.Buffer)
+	for _, line := range splitLines(text) {
+		fmt.Fprintf(buf, ": %s\n", line)
+	}
+	buf.WriteString("\n")
+	return s.writeRaw(buf.String())
+}
+
+func (s *EventStream) writeEvent(e Event) error {
+	if strings.ContainsAny(e.ID, "\r\n") || strings.ContainsAny(e.Event, "\r\n") {
+		return ErrInvalidEventField
+	}
+	buf := new(bytes.Buffer)
+	if e.ID != "" {
+		fmt.Fprintf(buf, "id: %s\n", e.ID)
+	}
+	if e.Event != "" {
+		fmt.Fprintf(buf, "event: %s\n", e.Event)
+	}
+	for _, line := range splitLines(e.Data) {
+		fmt.Fprintf(buf, "data: %s\n", line)
+	}
+	buf.WriteString("\n")
+	return s.writeRaw(buf.String())
+}
+
+// splitLines splits s on any line terminator allowed by the event stream format.
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.Split(s, "\n")
+}
+
+func (s *EventStream) writeRaw(payload string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := s.w.Write([]byte(payload)); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// SSE sends a `text/event-stream` response and calls fn to write its events.
+// The stream ends when fn returns or the client disconnects, which cancels
+// stream.Context(). The status is sent before fn runs, so the errors of fn
+// are logged instead of being written to the client. Reconnecting clients are replayed the events stored in
+// SSEOptions.History after their Last-Event-ID header.
+//
+//	history := i9.NewEventHistory(100)
+//	server.Get("/progress", func(c *i9.Context) error {
+//		return c.SSE(func(stream *i9.EventStream) error {
+//			for i := 0; i <= 100; i += 10 {
+//				if err := stream.Send("progress", fmt.Sprint(i), nine.JSON{"percent": i}); err != nil {
+//					return err
+//				}
+//				time.Sleep(time.Second)
+//			}
+//			return nil
+//		}, i9.SSEOptions{KeepAlive: 15 * time.Second, History: history})
+//	})
+func (c *Context) SSE(fn func(stream *EventStream) error, opts ...SSEOptions) error {
+	var options SSEOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	w := c.Response.HTTP()
+	if _, ok := w.(http.Flusher); !ok {
+		return ErrStreamingUnsupported
+	}
+	rc := http.NewResponseController(w)
+	return c.Response.write(func() error {
+		// Streams outlive the server WriteTimeout.
+		rc.SetWriteDeadline(time.Time{})
+		header := w.Header()
+		header.Set("Content-Type", "text/event-stream")
+		header.Set("Cache-Control", "no-cache")
+		header.Set("Connection", "keep-alive")
+		header.Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		if err := rc.Flush(); err != nil {
+			logSSEError(c, err)
+			return nil
+		}
+
+		stream := &EventStream{
+			ctx:     c.Request.Context(),
+			w:       w,
+			rc:      rc,
+			history: options.History,
+		}
+		if options.Retry > 0 {
+			if err := stream.Retry(options.Retry); err != nil {
+				return nil
+			}
+		}
+		if lastID := c.Header("Last-Event-ID"); lastID != "" && options.History != nil {
+			for _, e := range options.History.Since(lastID) {
+				if err := stream.writeEvent(e); err != nil {
+					return nil
+				}
+			}
+		}
+
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		if options.KeepAlive > 0 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ticker := time.NewTicker(options.KeepAlive)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-done:
+						return
+					case <-stream.ctx.Done():
+						return
+					case <-ticker.C:
+						if err := stream.Comment("keep-alive"); err != nil {
+							return
+						}
+					}
+				}
+			}()
+		}
+		err := fn(stream)
+		close(done)
+		wg.Wait()
+		if err != nil && (stream.ctx.Err() == nil || !errors.Is(err, stream.ctx.Err())) {
+			logSSEError(c, err)
+		}
+		return nil
+	})
+}
+
+func logSSEError(c *Context, err error) {
+	log.Printf("nine: %s: event stream error after the response was written: %v", c.Request.HTTP().URL.Path, err)
+}
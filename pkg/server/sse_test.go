@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/i9si-sistemas/assert"
+)
+
+func TestSSE(t *testing.T) {
+	history := NewEventHistory(2)
+	server := New(0)
+	server.Get("/events", func(c *Context) error {
+		return c.SSE(func(stream *EventStream) error {
+			if err := stream.Send("greeting", "1", "hello\nworld"); err != nil {
+				return err
+			}
+			if err := stream.Send("progress", "2", JSON{"percent": 50}); err != nil {
+				return err
+			}
+			return stream.Send("", "3", []byte("done"))
+		}, SSEOptions{Retry: 3 * time.Second, History: history})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	res := server.Test().Request(req)
+	assert.Equal(t, res.Code, http.StatusOK)
+	assert.Equal(t, res.Header().Get("Content-Type"), "text/event-stream")
+	assert.Equal(t, res.Header().Get("Cache-Control"), "no-cache")
+	expected := "retry: 3000\n\n" +
+		"id: 1\nevent: greeting\ndata: hello\ndata: world\n\n" +
+		"id: 2\nevent: progress\ndata: {\"percent\":50}\n\n" +
+		"id: 3\ndata: done\n\n"
+	assert.Equal(t, res.Body.String(), expected)
+
+	t.Run("Replay", func(t *testing.T) {
+		assert.Equal(t, len(history.Since("2")), 1)
+		assert.Equal(t, len(history.Since("1")), 0)
+
+		req := httptest.NewRequest(http.MethodGet, "/events", nil)
+		req.Header.Set("Last-Event-ID", "2")
+		res := server.Test().Request(req)
+		assert.True(t, strings.HasPrefix(res.Body.String(), "retry: 3000\n\nid: 3\ndata: done\n\nid: 1\n"))
+	})
+}
+
+func TestSSEInvalidFields(t *testing.T) {
+	server := New(0)
+	server.Get("/events", func(c *Context) error {
+		return c.SSE(func(stream *EventStream) error {
+			for _, event := range []string{"greeting\ndata: injected", "greeting\r"} {
+				if err := stream.Send(event, "1", "hello"); err != ErrInvalidEventField {
+					return err
+				}
+			}
+			if err := stream.Send("greeting", "1\r\nevent: injected", "hello"); err != ErrInvalidEventField {
+				return err
+			}
+			return stream.Send("greeting", "2", "hello")
+		})
+	})
+	res := server.Test().Request(httptest.NewRequest(http.MethodGet, "/events", nil))
+	assert.Equal(t, res.Body.String(), "id: 2\nevent: greeting\ndata: hello\n\n")
+}
+
+func TestSSEError(t *testing.T) {
+	logs := new(safeBuffer)
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(logs)
+	server := New(0)
+	server.Get("/events", func(c *Context) error {
+		return c.SSE(func(stream *EventStream) error {
+			if err := stream.Send("greeting", "1", "hello"); err != nil {
+				return err
+			}
+			return errors.New("upstream closed")
+		})
+	})
+	res := server.Test().Request(httptest.NewRequest(http.MethodGet, "/events", nil))
+	assert.Equal(t, res.Code, http.StatusOK)
+	assert.Equal(t, res.Body.String(), "id: 1\nevent: greeting\ndata: hello\n\n")
+	assert.True(t, strings.Contains(logs.String(), "/events: event stream error after the response was written: upstream closed"))
+}
+
+func TestSSEKeepAlive(t *testing.T) {
+	server := New(0)
+	server.Get("/events", func(c *Context) error {
+		return c.SSE(func(stream *EventStream) error {
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		}, SSEOptions{KeepAlive: 10 * time.Millisecond})
+	})
+	res := server.Test().Request(httptest.NewRequest(http.MethodGet, "/events", nil))
+	assert.True(t, strings.Contains(res.Body.String(), ": keep-alive\n\n"))
+}
+
+func TestSSEDisconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	server := New(0)
+	sent := make(chan error, 1)
+	server.Get("/events", func(c *Context) error {
+		return c.SSE(func(stream *EventStream) error {
+			cancel()
+			<-stream.Context().Done()
+			err := stream.Send("late", "", "data")
+			sent <- err
+			return err
+		})
+	})
+	req := httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx)
+	res := server.Test().Request(req)
+	assert.Equal(t, <-sent, context.Canceled)
+	assert.Equal(t, res.Code, http.StatusOK)
+	assert.Empty(t, res.Body.String())
+}
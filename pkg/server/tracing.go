@@ -0,0 +1,240 @@
+package server
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/i9si-sistemas/nine/internal/json"
+	"github.com/i9si-sistemas/nine/internal/tracecontext"
+)
+
+type (
+	// TraceID identifies a trace.
+	TraceID = tracecontext.TraceID
+	// SpanID identifies a span within a trace.
+	SpanID = tracecontext.SpanID
+	// SpanContext is the part of a span propagated across services
+	// through the W3C traceparent and tracestate headers.
+	SpanContext = tracecontext.SpanContext
+)
+
+// SpanKind tells the role of a span in a call.
+type SpanKind string
+
+const (
+	SpanKindServer SpanKind = "server"
+	SpanKindClient SpanKind = "client"
+)
+
+// SpanStatus tells whether the operation of a span failed.
+type SpanStatus string
+
+const (
+	SpanStatusUnset SpanStatus = "unset"
+	SpanStatusError SpanStatus = "error"
+)
+
+// Span is a finished span, as received by a SpanExporter.
+type Span struct {
+	// Name is the registered pattern of the route, as "GET /users/{id}".
+	Name        string
+	SpanContext SpanContext
+	// Parent is the span of the caller, invalid for the root span of a trace.
+	Parent     SpanID
+	Kind       SpanKind
+	StartTime  time.Time
+	EndTime    time.Time
+	Status     SpanStatus
+	Attributes map[string]any
+}
+
+// SpanExporter receives the sampled spans once they end, to send them to a
+// tracing backend. Export errors are ignored by the Tracing middleware.
+type SpanExporter interface {
+	ExportSpan(ctx context.Context, span Span) error
+}
+
+// TracingConfig configures the Tracing middleware.
+type TracingConfig struct {
+	// Exporter receives the sampled spans. Defaults to a JSON exporter
+	// writing to os.Stdout.
+	Exporter SpanExporter
+	// SampleRate is the fraction, between 0 and 1, of the new traces that
+	// are sampled. Zero samples every trace. Traces started by a caller
+	// keep its sampling decision.
+	SampleRate float64
+}
+
+// Tracing takes part in distributed traces through the W3C Trace Context
+// headers. Every request gets a server span, named after its route
+// pattern, which continues the trace of the traceparent header or starts
+// a new one. The span context is stored in the request context, where it
+// is read by Context.SpanContext and by the nine.Client created with that
+// context, which propagates it to the services it calls.
+//
+//	exporter := i9.NewMemoryExporter()
+//	server.Use(i9.Tracing(i9.TracingConfig{Exporter: exporter}))
+func Tracing(options ...TracingConfig) HandlerWithContext {
+	var config TracingConfig
+	if len(options) > 0 {
+		config = options[0]
+	}
+	if config.Exporter == nil {
+		config.Exporter = NewJSONExporter(os.Stdout)
+	}
+	return func(c *Context) error {
+		r := c.Request.HTTP()
+		parent, remote := tracecontext.Extract(r.Header)
+		sc := SpanContext{SpanID: tracecontext.NewSpanID()}
+		if remote {
+			sc.TraceID, sc.TraceFlags, sc.TraceState = parent.TraceID, parent.TraceFlags, parent.TraceState
+		} else {
+			sc.TraceID = tracecontext.NewTraceID()
+			if config.SampleRate <= 0 || rand.Float64() < config.SampleRate {
+				sc.TraceFlags = tracecontext.FlagSampled
+			}
+		}
+		c.Request.SetContext(ContextWithSpanContext(r.Context(), sc))
+		if !sc.IsSampled() {
+			return nil
+		}
+
+		start := time.Now()
+		w := newResponseWriter(c.Response.HTTP())
+		c.Response.ChangeResponseWriter(w)
+		c.Next()
+
+		span := Span{
+			Name:        r.Method + " " + routePath(c.PathRegistred()),
+			SpanContext: sc,
+			Kind:        SpanKindServer,
+			StartTime:   start,
+			EndTime:     time.Now(),
+			Status:      SpanStatusUnset,
+			Attributes: map[string]any{
+				"http.request.method":       r.Method,
+				"http.route":                routePath(c.PathRegistred()),
+				"url.path":                  r.URL.Path,
+				"url.scheme":                c.Protocol(),
+				"client.address":            c.IP(),
+				"http.response.status_code": w.Status(),
+				"http.response.body.size":   w.Bytes(),
+			},
+		}
+		if remote {
+			span.Parent = parent.SpanID
+		}
+		if ua := r.UserAgent(); ua != "" {
+			span.Attributes["user_agent.original"] = ua
+		}
+		if w.Status() >= http.StatusInternalServerError {
+			span.Status = SpanStatusError
+		}
+		config.Exporter.ExportSpan(context.WithoutCancel(r.Context()), span)
+		return nil
+	}
+}
+
+// SpanContext returns the span context of the request given by the
+// Tracing middleware.
+func (c *Context) SpanContext() SpanContext {
+	sc, _ := SpanContextFromContext(c.Request.Context())
+	return sc
+}
+
+// ContextWithSpanContext returns a copy of ctx carrying sc.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return tracecontext.NewContext(ctx, sc)
+}
+
+// SpanContextFromContext returns the span context carried by ctx, if any.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	return tracecontext.FromContext(ctx)
+}
+
+// NewJSONExporter returns a SpanExporter writing one JSON object per span
+// to w, os.Stdout when nil.
+func NewJSONExporter(w io.Writer) SpanExporter {
+	if w == nil {
+		w = os.Stdout
+	}
+	return &jsonExporter{enc: json.NewEncoder(w)}
+}
+
+type jsonExporter struct {
+	mu  sync.Mutex
+	enc json.Encoder
+}
+
+type jsonSpan struct {
+	Name       string         `json:"name"`
+	TraceID    string         `json:"trace_id"`
+	SpanID     string         `json:"span_id"`
+	Parent     string         `json:"parent_span_id,omitempty"`
+	TraceState string         `json:"trace_state,omitempty"`
+	Kind       SpanKind       `json:"kind"`
+	Start      time.Time      `json:"start"`
+	End        time.Time      `json:"end"`
+	Duration   float64        `json:"duration_ms"`
+	Status     SpanStatus     `json:"status"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+func (e *jsonExporter) ExportSpan(_ context.Context, span Span) error {
+	s := jsonSpan{
+		Name:       span.Name,
+		TraceID:    span.SpanContext.TraceID.String(),
+		SpanID:     span.SpanContext.SpanID.String(),
+		TraceState: span.SpanContext.TraceState,
+		Kind:       span.Kind,
+		Start:      span.StartTime,
+		End:        span.EndTime,
+		Duration:   float64(span.EndTime.Sub(span.StartTime).Microseconds()) / 1000,
+		Status:     span.Status,
+		Attributes: span.Attributes,
+	}
+	if span.Parent.IsValid() {
+		s.Parent = span.Parent.String()
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.enc.Encode(s)
+}
+
+// MemoryExporter is a SpanExporter keeping the spans in memory, for tests.
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []Span
+}
+
+// NewMemoryExporter returns an empty MemoryExporter.
+func NewMemoryExporter() *MemoryExporter {
+	return new(MemoryExporter)
+}
+
+func (e *MemoryExporter) ExportSpan(_ context.Context, span Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+	return nil
+}
+
+// Spans returns the spans exported so far, in the order they ended.
+func (e *MemoryExporter) Spans() []Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return slices.Clone(e.spans)
+}
+
+// Reset removes the exported spans.
+func (e *MemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
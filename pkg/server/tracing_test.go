@@ -0,0 +1,111 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/i9si-sistemas/assert"
+	"github.com/i9si-sistemas/nine/internal/client"
+	"github.com/i9si-sistemas/nine/internal/json"
+	public "github.com/i9si-sistemas/nine/pkg/client"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := NewMemoryExporter()
+	downstream := New(0)
+	downstream.Use(Tracing(TracingConfig{Exporter: exporter}))
+	downstream.Get("/stock/:sku", func(c *Context) error {
+		return c.SendStatus(http.StatusServiceUnavailable)
+	})
+	backend := httptest.NewServer(downstream.Handler())
+	defer backend.Close()
+
+	upstream := New(0)
+	upstream.Use(Tracing(TracingConfig{Exporter: exporter}))
+	var sc SpanContext
+	upstream.Post("/orders", func(c *Context) error {
+		sc = c.SpanContext()
+		client.New(c.Request.Context()).Get(backend.URL+"/stock/42", &public.Options{})
+		return c.SendStatus(http.StatusAccepted)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set("tracestate", "rojo=1")
+	res := upstream.Test().Request(req)
+	assert.Equal(t, res.Code, http.StatusAccepted)
+	assert.Equal(t, sc.TraceID.String(), "4bf92f3577b34da6a3ce929d0e0e4736")
+
+	spans := exporter.Spans()
+	assert.Equal(t, len(spans), 2)
+	child, parent := spans[0], spans[1]
+	assert.Equal(t, parent.Name, "POST /orders")
+	assert.Equal(t, parent.SpanContext, sc)
+	assert.Equal(t, parent.Parent.String(), "00f067aa0ba902b7")
+	assert.Equal(t, parent.Kind, SpanKindServer)
+	assert.Equal(t, parent.Status, SpanStatusUnset)
+	assert.Equal(t, child.Name, "GET /stock/{sku}")
+	assert.Equal(t, child.SpanContext.TraceID, sc.TraceID)
+	assert.Equal(t, child.SpanContext.TraceState, "rojo=1")
+	assert.Equal(t, child.Parent, sc.SpanID)
+	assert.Equal(t, child.Status, SpanStatusError)
+	assert.Equal(t, child.Attributes["http.route"], "/stock/{sku}")
+	assert.Equal(t, child.Attributes["url.path"], "/stock/42")
+	assert.Equal(t, child.Attributes["http.response.status_code"], http.StatusServiceUnavailable)
+	assert.False(t, child.StartTime.After(child.EndTime))
+
+	exporter.Reset()
+	assert.Equal(t, len(exporter.Spans()), 0)
+}
+
+func TestTracingSampling(t *testing.T) {
+	exporter := NewMemoryExporter()
+	server := New(0)
+	server.Use(Tracing(TracingConfig{Exporter: exporter, SampleRate: 1e-9}))
+	var sc SpanContext
+	server.Get("/", func(c *Context) error {
+		sc = c.SpanContext()
+		return c.SendString("ok")
+	})
+
+	server.Test().Request(httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.True(t, sc.IsValid())
+	assert.False(t, sc.IsSampled())
+	assert.Equal(t, len(exporter.Spans()), 0)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	server.Test().Request(req)
+	assert.Equal(t, len(exporter.Spans()), 1)
+	assert.Equal(t, exporter.Spans()[0].Parent.String(), "00f067aa0ba902b7")
+}
+
+func TestJSONExporter(t *testing.T) {
+	out := new(bytes.Buffer)
+	start := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	sc := SpanContext{TraceID: TraceID{1}, SpanID: SpanID{2}, TraceFlags: 1}
+	err := NewJSONExporter(out).ExportSpan(context.Background(), Span{
+		Name:        "GET /",
+		SpanContext: sc,
+		Kind:        SpanKindServer,
+		StartTime:   start,
+		EndTime:     start.Add(1500 * time.Microsecond),
+		Status:      SpanStatusUnset,
+		Attributes:  map[string]any{"http.route": "/"},
+	})
+	assert.NoError(t, err)
+
+	var record JSON
+	assert.NoError(t, json.Decode(out.Bytes(), &record))
+	assert.Equal(t, record["name"], "GET /")
+	assert.Equal(t, record["trace_id"], "01000000000000000000000000000000")
+	assert.Equal(t, record["span_id"], "0200000000000000")
+	assert.Nil(t, record["parent_span_id"])
+	assert.Equal(t, record["kind"], "server")
+	assert.Equal(t, record["duration_ms"], 1.5)
+	assert.Equal(t, record["attributes"], map[string]any{"http.route": "/"})
+}
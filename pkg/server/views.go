@@ -0,0 +1,278 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/i9si-sistemas/stringx"
+)
+
+// ContentTemplate is the name under which a page is made available to its layout.
+//
+//	<!-- layouts/main.html -->
+//	<html><body>{{ template "content" . }}</body></html>
+const ContentTemplate = "content"
+
+// DefaultViewsExtension is the file extension loaded when ViewsConfig.Extension is empty.
+const DefaultViewsExtension = ".html"
+
+// ErrViewsNotConfigured is returned by Context.Render when the server has no ViewEngine.
+var ErrViewsNotConfigured = errors.New("views not configured")
+
+// ViewEngine renders named templates. It is used by Context.Render and
+// can be set through ServerOpts.Views.
+type ViewEngine interface {
+	// Render writes the template name executed with data to w,
+	// wrapped by the first given layout.
+	Render(w io.Writer, name string, data any, layout ...string) error
+}
+
+// ViewsConfig configures the html/template engine returned by NewViews.
+type ViewsConfig struct {
+	// Dir is the directory templates are loaded from. Ignored when FS is set.
+	Dir string
+	// FS is the filesystem templates are loaded from, such as an embed.FS.
+	FS fs.FS
+	// Extension filters the loaded files. Defaults to ".html".
+	Extension string
+	// Layout is the layout applied when Render is called without one.
+	Layout string
+	// Funcs are the functions available to every template.
+	Funcs template.FuncMap
+	// Reload re-parses the templates whenever a file changes.
+	// Meant for development; it also shows template errors on the error page.
+	Reload bool
+	// ErrorTemplate is the template rendered when a page fails.
+	// It receives the StatusCode and Error fields.
+	ErrorTemplate string
+}
+
+// Views is an html/template based ViewEngine.
+//
+// Every file under the configured directory is parsed into a single set and
+// named after its path without the extension, so "partials/header.html" is
+// included with {{ template "partials/header" . }}. Layouts are regular
+// templates that include the page through {{ template "content" . }}.
+//
+//	views := NewViews(ViewsConfig{Dir: "./views", Layout: "layouts/main"})
+//	server := New(8080, ServerOpts{Views: views})
+//	server.Get("/admin", func(c *Context) error {
+//		return c.Render("admin/index", JSON{"title": "Admin"})
+//	})
+type Views struct {
+	config ViewsConfig
+	fsys   fs.FS
+
+	mu        sync.RWMutex
+	funcs     template.FuncMap
+	templates *template.Template
+	cache     map[string]*template.Template
+	stamp     string
+}
+
+// NewViews creates a new Views engine. Templates are parsed on the first Render.
+func NewViews(config ViewsConfig) *Views {
+	if config.Extension == "" {
+		config.Extension = DefaultViewsExtension
+	}
+	fsys := config.FS
+	if fsys == nil {
+		fsys = os.DirFS(config.Dir)
+	}
+	funcs := template.FuncMap{}
+	for name, fn := range config.Funcs {
+		funcs[name] = fn
+	}
+	return &Views{
+		config: config,
+		fsys:   fsys,
+		funcs:  funcs,
+		cache:  map[string]*template.Template{},
+	}
+}
+
+// AddFunc registers a template function. Templates are re-parsed on the
+// next Render so the function becomes available to them.
+func (v *Views) AddFunc(name string, fn any) *Views {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.funcs[name] = fn
+	v.templates = nil
+	return v
+}
+
+// Load parses every template, replacing the ones previously loaded.
+func (v *Views) Load() error {
+	stamp, err := v.fingerprint()
+	if err != nil {
+		return err
+	}
+	return v.parse(stamp)
+}
+
+// Render executes the template name with data and writes it to w.
+// The first layout given replaces ViewsConfig.Layout; an empty one disables it.
+func (v *Views) Render(w io.Writer, name string, data any, layout ...string) error {
+	layoutName := v.config.Layout
+	if len(layout) > 0 {
+		layoutName = layout[0]
+	}
+	tmpl, err := v.lookup(name, layoutName)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, data)
+}
+
+// lookup returns the executable template for the page and layout pair.
+// Pairs are cloned from the parsed set once and cached, since an
+// html/template set can not be cloned after it has been executed.
+func (v *Views) lookup(name, layout string) (*template.Template, error) {
+	if err := v.refresh(); err != nil {
+		return nil, err
+	}
+	key := layout + "\x00" + name
+
+	v.mu.RLock()
+	tmpl, ok := v.cache[key]
+	v.mu.RUnlock()
+	if ok {
+		return tmpl, nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if tmpl, ok := v.cache[key]; ok {
+		return tmpl, nil
+	}
+	if v.templates == nil {
+		return nil, fmt.Errorf("template %q not loaded", name)
+	}
+	set, err := v.templates.Clone()
+	if err != nil {
+		return nil, err
+	}
+	page := set.Lookup(name)
+	if page == nil {
+		return nil, fmt.Errorf("template %q not found", name)
+	}
+	tmpl = page
+	if layout != "" {
+		if tmpl = set.Lookup(layout); tmpl == nil {
+			return nil, fmt.Errorf("layout %q not found", layout)
+		}
+		if _, err := set.AddParseTree(ContentTemplate, page.Tree.Copy()); err != nil {
+			return nil, err
+		}
+	}
+	v.cache[key] = tmpl
+	return tmpl, nil
+}
+
+// refresh parses the templates when they were never loaded
+// or, in reload mode, when a file changed since the last parse.
+func (v *Views) refresh() error {
+	v.mu.RLock()
+	loaded, stamp := v.templates != nil, v.stamp
+	v.mu.RUnlock()
+	if loaded && !v.config.Reload {
+		return nil
+	}
+	current, err := v.fingerprint()
+	if err != nil {
+		return err
+	}
+	if loaded && current == stamp {
+		return nil
+	}
+	return v.parse(current)
+}
+
+func (v *Views) parse(stamp string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	root := template.New("").Funcs(v.funcs)
+	err := v.walk(func(name string, _ fs.FileInfo) error {
+		b, err := fs.ReadFile(v.fsys, name)
+		if err != nil {
+			return err
+		}
+		_, err = root.New(stringx.String(name).TrimSuffix(v.config.Extension).String()).Parse(string(b))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	v.templates = root
+	v.stamp = stamp
+	v.cache = map[string]*template.Template{}
+	return nil
+}
+
+// fingerprint summarizes the name, size and modification time of every template.
+func (v *Views) fingerprint() (string, error) {
+	builder := stringx.NewBuilder()
+	err := v.walk(func(name string, info fs.FileInfo) error {
+		fmt.Fprintf(builder, "%s:%d:%d;", name, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return builder.String(), err
+}
+
+func (v *Views) walk(fn func(name string, info fs.FileInfo) error) error {
+	return fs.WalkDir(v.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(name) != v.config.Extension {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(name, info)
+	})
+}
+
+// renderError writes the page shown when a template fails. The error
+// message is only exposed in reload mode. A nil *Views, used for other
+// engines, always writes the built-in page.
+func (v *Views) renderError(w io.Writer, err error) error {
+	data := JSON{
+		"StatusCode": http.StatusInternalServerError,
+		"Error":      http.StatusText(http.StatusInternalServerError),
+	}
+	if v == nil {
+		return errorPage.Execute(w, data)
+	}
+	if v.config.Reload {
+		data["Error"] = err.Error()
+	}
+	if v.config.ErrorTemplate != "" {
+		buf := new(bytes.Buffer)
+		if err := v.Render(buf, v.config.ErrorTemplate, data, ""); err == nil {
+			_, err = w.Write(buf.Bytes())
+			return err
+		}
+	}
+	return errorPage.Execute(w, data)
+}
+
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head><title>{{ .StatusCode }}</title></head>
+<body>
+<h1>{{ .StatusCode }}</h1>
+<pre>{{ .Error }}</pre>
+</body>
+</html>
+`))
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/i9si-sistemas/assert"
+)
+
+func TestViews(t *testing.T) {
+	files := fstest.MapFS{
+		"layouts/main.html":   {Data: []byte(`<main>{{ template "partials/title" . }}{{ template "content" . }}</main>`)},
+		"partials/title.html": {Data: []byte(`<h1>{{ .title | upper }}</h1>`)},
+		"admin/index.html":    {Data: []byte(`<p>{{ .message }}</p>`)},
+		"broken.html":         {Data: []byte(`{{ template "missing" . }}`)},
+		"notes.txt":           {Data: []byte(`ignored`)},
+	}
+	newViews := func(reload bool) *Views {
+		return NewViews(ViewsConfig{
+			FS:     files,
+			Layout: "layouts/main",
+			Funcs:  map[string]any{"upper": strings.ToUpper},
+			Reload: reload,
+		})
+	}
+	data := JSON{"title": "admin", "message": "<hello>"}
+
+	t.Run("Layout", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		assert.NoError(t, newViews(false).Render(buf, "admin/index", data))
+		assert.Equal(t, buf.String(), `<main><h1>ADMIN</h1><p>&lt;hello&gt;</p></main>`)
+	})
+
+	t.Run("WithoutLayout", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		assert.NoError(t, newViews(false).Render(buf, "admin/index", data, ""))
+		assert.Equal(t, buf.String(), `<p>&lt;hello&gt;</p>`)
+	})
+
+	t.Run("AddFunc", func(t *testing.T) {
+		views := newViews(false)
+		views.AddFunc("upper", strings.ToLower)
+		buf := new(bytes.Buffer)
+		assert.NoError(t, views.Render(buf, "partials/title", data, ""))
+		assert.Equal(t, buf.String(), `<h1>admin</h1>`)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		err := newViews(false).Render(new(bytes.Buffer), "missing", data)
+		assert.Error(t, err)
+	})
+
+	t.Run("Context", func(t *testing.T) {
+		server := New(0, ServerOpts{Views: newViews(false)})
+		server.Get("/admin", func(c *Context) error {
+			return c.Render("admin/index", data)
+		})
+		server.Get("/broken", func(c *Context) error {
+			return c.Render("broken", nil, "")
+		})
+		res := server.Test().Request(httptest.NewRequest(http.MethodGet, "/admin", nil))
+		assert.Equal(t, res.Code, http.StatusOK)
+		assert.Equal(t, res.Header().Get("Content-Type"), "text/html; charset=utf-8")
+		assert.Equal(t, res.Body.String(), `<main><h1>ADMIN</h1><p>&lt;hello&gt;</p></main>`)
+
+		res = server.Test().Request(httptest.NewRequest(http.MethodGet, "/broken", nil))
+		assert.Equal(t, res.Code, http.StatusInternalServerError)
+		assert.True(t, strings.Contains(res.Body.String(), "Internal Server Error"))
+	})
+
+	t.Run("NotConfigured", func(t *testing.T) {
+		server := New(0)
+		server.Get("/", func(c *Context) error {
+			return c.Render("index", nil)
+		})
+		res := server.Test().Request(httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, res.Code, http.StatusInternalServerError)
+		assert.Equal(t, strings.TrimSpace(res.Body.String()), ErrViewsNotConfigured.Error())
+	})
+}
+
+func TestViewsReload(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "index.html")
+	assert.NoError(t, os.WriteFile(filePath, []byte(`v1`), 0644))
+
+	views := NewViews(ViewsConfig{Dir: dir, Reload: true})
+	buf := new(bytes.Buffer)
+	assert.NoError(t, views.Render(buf, "index", nil))
+	assert.Equal(t, buf.String(), "v1")
+
+	assert.NoError(t, os.WriteFile(filePath, []byte(`v2 {{ .Missing`), 0644))
+	future := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(filePath, future, future))
+	assert.Error(t, views.Render(new(bytes.Buffer), "index", nil))
+
+	buf.Reset()
+	assert.NoError(t, views.renderError(buf, os.ErrNotExist))
+	assert.True(t, strings.Contains(buf.String(), os.ErrNotExist.Error()))
+}
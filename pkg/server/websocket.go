@@ -0,0 +1,305 @@
+package server
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// websocketGUID is appended to the client key to compute Sec-WebSocket-Accept.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// ErrHijackUnsupported is returned when the response writer can not be hijacked.
+var ErrHijackUnsupported = errors.New("websocket: response does not implement http.Hijacker")
+
+// WebSocketHandler handles an upgraded WebSocket connection.
+// The connection is closed when it returns: normally when the error is nil
+// and with CloseInternalServerErr otherwise.
+type WebSocketHandler func(conn *WebSocketConn) error
+
+// WebSocketConfig configures a WebSocket endpoint. It can be passed to
+// Server.WebSocket before the WebSocketHandler.
+type WebSocketConfig struct {
+	// Origins lists the allowed Origin header values, "*" allows any origin.
+	// When empty, only same-origin requests and requests without Origin are accepted.
+	Origins []string
+	// CheckOrigin replaces the Origins check when set.
+	CheckOrigin func(r *http.Request) bool
+	// Subprotocols lists the supported subprotocols in order of preference.
+	Subprotocols []string
+	// ReadLimit is the maximum size in bytes of a message, which defaults
+	// to DefaultWebSocketReadLimit. A negative limit disables it.
+	ReadLimit int64
+	// FragmentSize splits written messages into frames of at most this size.
+	// Zero writes every message as a single frame.
+	FragmentSize int
+}
+
+// WebSocket registers a WebSocket endpoint. The last handler must be a
+// WebSocketHandler, optionally preceded by a WebSocketConfig and by
+// middlewares, which run before the upgrade and can reject it.
+//
+//	server.WebSocket("/chat", authMiddleware, i9.WebSocketConfig{ReadLimit: 1 << 20},
+//		func(conn *i9.WebSocketConn) error {
+//			for {
+//				var msg i9.JSON
+//				if err := conn.ReadJSON(&msg); err != nil {
+//					return err
+//				}
+//				if err := conn.WriteJSON(msg); err != nil {
+//					return err
+//				}
+//			}
+//		})
+func (s *Server) WebSocket(endpoint string, handlers ...any) error {
+	handler, middlewares, err := registerWebSocketHandlers(handlers...)
+	if err != nil {
+		return err
+	}
+	r := Router{
+		pattern:     s.routePattern(http.MethodGet, endpoint),
+		handler:     handler,
+		middlewares: middlewares,
+	}
+	return s.registerRoute(r)
+}
+
+// registerWebSocketHandlers splits the WebSocket handler and config from the middlewares.
+func registerWebSocketHandlers(handlers ...any) (Handler, []Handler, error) {
+	if len(handlers) == 0 {
+		return nil, nil, ErrPutAHandler
+	}
+	lastIndex := len(handlers) - 1
+	var wsHandler WebSocketHandler
+	switch h := handlers[lastIndex].(type) {
+	case WebSocketHandler:
+		wsHandler = h
+	case func(conn *WebSocketConn) error:
+		wsHandler = h
+	default:
+		return nil, nil, fmt.Errorf("final handler: invalid handler type: %v - must be nine.WebSocketHandler", reflect.TypeOf(h))
+	}
+	var (
+		config      WebSocketConfig
+		middlewares []Handler
+	)
+	for i := range lastIndex {
+		if c, ok := handlers[i].(WebSocketConfig); ok {
+			config = c
+			continue
+		}
+		middleware, err := validateHandler(handlers[i])
+		if err != nil {
+			return nil, nil, fmt.Errorf("middleware at position %d: %w", i, err)
+		}
+		middlewares = append(middlewares, middleware)
+	}
+	return wsHandler.handler(config), middlewares, nil
+}
+
+func (h WebSocketHandler) handler(config WebSocketConfig) Handler {
+	return func(req *Request, res *Response) error {
+		conn, err := upgradeWebSocket(res.HTTP(), req.HTTP(), config)
+		if err != nil {
+			return err
+		}
+		return res.write(func() error {
+			err := h(conn)
+			switch {
+			case err == nil, IsCloseError(err):
+				conn.Close()
+			default:
+				conn.WriteClose(CloseInternalServerErr, "")
+				conn.closeConn()
+			}
+			return nil
+		})
+	}
+}
+
+// upgradeWebSocket validates the opening handshake and hijacks the connection.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request, config WebSocketConfig) (*WebSocketConn, error) {
+	handshakeError := func(code int, message string) error {
+		return &Error{StatusCode: code, Err: errors.New(message)}
+	}
+	if r.Method != http.MethodGet {
+		return nil, handshakeError(http.StatusMethodNotAllowed, "websocket: method not allowed")
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") ||
+		!headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return nil, handshakeError(http.StatusBadRequest, "websocket: not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, handshakeError(http.StatusUpgradeRequired, "websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, handshakeError(http.StatusBadRequest, "websocket: invalid Sec-WebSocket-Key")
+	}
+	if !config.allowOrigin(r) {
+		return nil, handshakeError(http.StatusForbidden, "websocket: origin not allowed")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, ErrHijackUnsupported
+	}
+	subprotocol := config.negotiate(r)
+
+	netConn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	// Hijacked connections keep the deadlines set by the server timeouts.
+	netConn.SetDeadline(time.Time{})
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n"
+	if subprotocol != "" {
+		response += "Sec-WebSocket-Protocol: " + subprotocol + "\r\n"
+	}
+	if _, err := netConn.Write([]byte(response + "\r\n")); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	conn := newWebSocketConn(netConn, rw.Reader, r, true, config)
+	conn.subprotocol = subprotocol
+	return conn, nil
+}
+
+func (config WebSocketConfig) allowOrigin(r *http.Request) bool {
+	if config.CheckOrigin != nil {
+		return config.CheckOrigin(r)
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if len(config.Origins) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	for _, allowed := range config.Origins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (config WebSocketConfig) negotiate(r *http.Request) string {
+	requested := headerTokens(r.Header, "Sec-WebSocket-Protocol")
+	for _, supported := range config.Subprotocols {
+		for _, protocol := range requested {
+			if protocol == supported {
+				return protocol
+			}
+		}
+	}
+	return ""
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerTokens(header http.Header, name string) []string {
+	var tokens []string
+	for _, value := range header.Values(name) {
+		for _, token := range splitComma(value) {
+			if token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
+
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, t := range headerTokens(header, name) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// WebSocket starts a test listener for the server and dials the endpoint,
+// returning the client side of the connection. The handshake response is
+// returned even when the upgrade is rejected, so its status can be inspected.
+// The listener is closed together with the connection.
+//
+//	conn, _, err := server.Test().WebSocket("/chat")
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer conn.Close()
+//	conn.WriteJSON(nine.JSON{"message": "hello"})
+func (t *TestServer) WebSocket(endpoint string, header ...http.Header) (*WebSocketConn, *http.Response, error) {
+	srv := httptest.NewServer(t.Handler())
+	conn, res, err := dialWebSocket(srv.Listener.Addr().String(), endpoint, header...)
+	if err != nil {
+		srv.Close()
+		return nil, res, err
+	}
+	conn.onClose = srv.Close
+	return conn, res, nil
+}
+
+func dialWebSocket(addr, endpoint string, header ...http.Header) (*WebSocketConn, *http.Response, error) {
+	netConn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		netConn.Close()
+		return nil, nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+	req, err := http.NewRequest(http.MethodGet, "http://"+addr+endpoint, nil)
+	if err != nil {
+		netConn.Close()
+		return nil, nil, err
+	}
+	for _, h := range header {
+		for name, values := range h {
+			req.Header[name] = values
+		}
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	if err := req.Write(netConn); err != nil {
+		netConn.Close()
+		return nil, nil, err
+	}
+	br := bufio.NewReader(netConn)
+	res, err := http.ReadResponse(br, req)
+	if err != nil {
+		netConn.Close()
+		return nil, nil, err
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols ||
+		res.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		netConn.Close()
+		return nil, res, fmt.Errorf("websocket: bad handshake: %s", res.Status)
+	}
+	conn := newWebSocketConn(netConn, br, req, false, WebSocketConfig{})
+	conn.subprotocol = res.Header.Get("Sec-WebSocket-Protocol")
+	return conn, res, nil
+}
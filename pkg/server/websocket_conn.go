@@ -0,0 +1,470 @@
+package server
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/i9si-sistemas/nine/internal/json"
+)
+
+// WebSocket message types, as defined by RFC 6455.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+
+	continuationFrame = 0
+)
+
+// WebSocket close codes, as defined by RFC 6455.
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseInternalServerErr       = 1011
+)
+
+const maxControlPayload = 125
+
+// DefaultWebSocketReadLimit is the maximum size in bytes of a message read
+// from the peer when no other limit is set.
+const DefaultWebSocketReadLimit = 32 << 20
+
+// ErrCloseSent is returned when writing after a close frame was sent.
+var ErrCloseSent = errors.New("websocket: close sent")
+
+// CloseError is returned by the read methods when the connection is closed
+// by the peer or because it violated the protocol.
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+// IsCloseError reports whether err is a *CloseError with one of the given codes,
+// or with any code when none is given.
+func IsCloseError(err error, codes ...int) bool {
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) {
+		return false
+	}
+	if len(codes) == 0 {
+		return true
+	}
+	for _, code := range codes {
+		if closeErr.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// WebSocketConn is a WebSocket connection.
+//
+// Reads must happen from a single goroutine, while writes are
+// safe for concurrent use.
+type WebSocketConn struct {
+	conn         net.Conn
+	br           *bufio.Reader
+	req          *http.Request
+	isServer     bool
+	subprotocol  string
+	readLimit    int64
+	fragmentSize int
+
+	writeMu   sync.Mutex
+	closeSent bool
+	closeOnce sync.Once
+	onClose   func()
+
+	pingHandler func(data []byte) error
+	pongHandler func(data []byte) error
+}
+
+type wsFrame struct {
+	fin     bool
+	opcode  int
+	payload []byte
+}
+
+func newWebSocketConn(conn net.Conn, br *bufio.Reader, req *http.Request, isServer bool, config WebSocketConfig) *WebSocketConn {
+	c := &WebSocketConn{
+		conn:         conn,
+		br:           br,
+		req:          req,
+		isServer:     isServer,
+		fragmentSize: config.FragmentSize,
+	}
+	c.SetReadLimit(config.ReadLimit)
+	c.pingHandler = func(data []byte) error {
+		if err := c.writeControl(PongMessage, data); err != nil && err != ErrCloseSent {
+			return err
+		}
+		return nil
+	}
+	c.pongHandler = func([]byte) error { return nil }
+	return c
+}
+
+// Request returns the HTTP request that was upgraded, so handlers can
+// read values set by the middlewares that ran before the upgrade.
+func (c *WebSocketConn) Request() *http.Request {
+	return c.req
+}
+
+// Subprotocol returns the negotiated subprotocol, if any.
+func (c *WebSocketConn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// RemoteAddr returns the remote network address.
+func (c *WebSocketConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// SetReadLimit sets the maximum size in bytes of a message read from the peer.
+// Bigger messages close the connection with CloseMessageTooBig. Zero restores
+// DefaultWebSocketReadLimit and a negative limit disables it.
+func (c *WebSocketConn) SetReadLimit(limit int64) {
+	if limit == 0 {
+		limit = DefaultWebSocketReadLimit
+	}
+	c.readLimit = limit
+}
+
+// SetFragmentSize splits written messages into frames of at most n bytes.
+// Zero writes every message as a single frame.
+func (c *WebSocketConn) SetFragmentSize(n int) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.fragmentSize = n
+}
+
+// SetReadDeadline sets the deadline for future reads.
+func (c *WebSocketConn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline for future writes.
+func (c *WebSocketConn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// SetPingHandler sets the function called when a ping is received.
+// The default handler answers with a pong carrying the same data.
+func (c *WebSocketConn) SetPingHandler(h func(data []byte) error) {
+	c.pingHandler = h
+}
+
+// SetPongHandler sets the function called when a pong is received.
+func (c *WebSocketConn) SetPongHandler(h func(data []byte) error) {
+	c.pongHandler = h
+}
+
+// ReadMessage reads the next text or binary message, joining fragmented
+// frames. Control frames received meanwhile are handled automatically.
+// When the peer closes the connection, it returns a *CloseError.
+func (c *WebSocketConn) ReadMessage() (messageType int, data []byte, err error) {
+	var message []byte
+	for {
+		remaining := int64(-1)
+		if c.readLimit > 0 {
+			remaining = c.readLimit - int64(len(message))
+		}
+		f, err := c.readFrame(remaining)
+		if err != nil {
+			return 0, nil, err
+		}
+		switch f.opcode {
+		case PingMessage:
+			if err := c.pingHandler(f.payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			if err := c.pongHandler(f.payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case CloseMessage:
+			return 0, nil, c.handleClose(f.payload)
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "expected continuation frame")
+			}
+			messageType = f.opcode
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		default:
+			return 0, nil, c.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", f.opcode))
+		}
+		message = append(message, f.payload...)
+		if f.fin {
+			if messageType == TextMessage && !utf8.Valid(message) {
+				return 0, nil, c.fail(CloseInvalidFramePayloadData, "invalid utf-8")
+			}
+			return messageType, message, nil
+		}
+	}
+}
+
+// ReadJSON reads the next message and decodes it as JSON into v.
+func (c *WebSocketConn) ReadJSON(v any) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Decode(data, v)
+}
+
+// WriteMessage writes a text or binary message. Messages bigger than
+// WebSocketConfig.FragmentSize are split into continuation frames.
+func (c *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("websocket: invalid message type %d", messageType)
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.fragmentSize <= 0 || len(data) <= c.fragmentSize {
+		return c.writeFrame(messageType, true, data)
+	}
+	opcode := messageType
+	for len(data) > c.fragmentSize {
+		if err := c.writeFrame(opcode, false, data[:c.fragmentSize]); err != nil {
+			return err
+		}
+		data = data[c.fragmentSize:]
+		opcode = continuationFrame
+	}
+	return c.writeFrame(opcode, true, data)
+}
+
+// WriteJSON encodes v as JSON and writes it as a text message.
+func (c *WebSocketConn) WriteJSON(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(TextMessage, b)
+}
+
+// Ping sends a ping with the given application data.
+func (c *WebSocketConn) Ping(data []byte) error {
+	return c.writeControl(PingMessage, data)
+}
+
+// WriteClose sends a close frame with the given code and reason.
+// The peer answers with its own close frame, returned as a
+// *CloseError by the next read.
+func (c *WebSocketConn) WriteClose(code int, text string) error {
+	return c.writeControl(CloseMessage, closePayload(code, text))
+}
+
+// Close sends a normal closure frame, when none was sent yet,
+// and closes the underlying connection.
+func (c *WebSocketConn) Close() error {
+	_ = c.WriteClose(CloseNormalClosure, "")
+	return c.closeConn()
+}
+
+func (c *WebSocketConn) closeConn() error {
+	var err error
+	c.closeOnce.Do(func() {
+		err = c.conn.Close()
+		if c.onClose != nil {
+			c.onClose()
+		}
+	})
+	return err
+}
+
+func (c *WebSocketConn) handleClose(payload []byte) error {
+	closeErr := &CloseError{Code: CloseNoStatusReceived}
+	switch {
+	case len(payload) == 1:
+		return c.fail(CloseProtocolError, "invalid close payload")
+	case len(payload) >= 2:
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Text = string(payload[2:])
+		if !validCloseCode(closeErr.Code) {
+			return c.fail(CloseProtocolError, "invalid close code")
+		}
+		if !utf8.ValidString(closeErr.Text) {
+			return c.fail(CloseInvalidFramePayloadData, "invalid utf-8")
+		}
+	}
+	code := closeErr.Code
+	if code == CloseNoStatusReceived {
+		code = CloseNormalClosure
+	}
+	_ = c.WriteClose(code, "")
+	return closeErr
+}
+
+// fail closes the connection because the peer broke the protocol.
+func (c *WebSocketConn) fail(code int, text string) error {
+	_ = c.WriteClose(code, text)
+	return &CloseError{Code: code, Text: text}
+}
+
+// readFrame reads the next frame, failing when its payload exceeds limit
+// bytes, unless limit is negative.
+func (c *WebSocketConn) readFrame(limit int64) (wsFrame, error) {
+	var f wsFrame
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return f, err
+	}
+	f.fin = header[0]&0x80 != 0
+	f.opcode = int(header[0] & 0x0f)
+	if header[0]&0x70 != 0 {
+		return f, c.fail(CloseProtocolError, "reserved bits set")
+	}
+	masked := header[1]&0x80 != 0
+	if masked != c.isServer {
+		return f, c.fail(CloseProtocolError, "invalid frame masking")
+	}
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return f, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return f, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+		if length>>63 != 0 {
+			return f, c.fail(CloseProtocolError, "invalid payload length")
+		}
+	}
+	if f.opcode >= CloseMessage {
+		if !f.fin || length > maxControlPayload {
+			return f, c.fail(CloseProtocolError, "invalid control frame")
+		}
+	} else if limit >= 0 && length > uint64(limit) {
+		return f, c.fail(CloseMessageTooBig, "message too big")
+	}
+
+	var key [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, key[:]); err != nil {
+			return f, err
+		}
+	}
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, f.payload); err != nil {
+		return f, err
+	}
+	if masked {
+		maskBytes(key, f.payload)
+	}
+	return f, nil
+}
+
+func (c *WebSocketConn) writeControl(opcode int, data []byte) error {
+	if len(data) > maxControlPayload {
+		return fmt.Errorf("websocket: control frame payload bigger than %d bytes", maxControlPayload)
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.writeFrame(opcode, true, data)
+}
+
+// writeFrame writes a single frame. Callers must hold writeMu.
+func (c *WebSocketConn) writeFrame(opcode int, fin bool, data []byte) error {
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	frame := make([]byte, 0, 14+len(data))
+	b0 := byte(opcode)
+	if fin {
+		b0 |= 0x80
+	}
+	frame = append(frame, b0)
+
+	var maskBit byte
+	if !c.isServer {
+		maskBit = 0x80
+	}
+	length := len(data)
+	switch {
+	case length <= maxControlPayload:
+		frame = append(frame, maskBit|byte(length))
+	case length <= 0xffff:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+
+	if c.isServer {
+		frame = append(frame, data...)
+	} else {
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		frame = append(frame, key[:]...)
+		start := len(frame)
+		frame = append(frame, data...)
+		maskBytes(key, frame[start:])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		return err
+	}
+	if opcode == CloseMessage {
+		c.closeSent = true
+	}
+	return nil
+}
+
+func maskBytes(key [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= key[i%4]
+	}
+}
+
+func closePayload(code int, text string) []byte {
+	if code == CloseNoStatusReceived {
+		return nil
+	}
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	return append(payload, text...)
+}
+
+func validCloseCode(code int) bool {
+	switch code {
+	case 1004, CloseNoStatusReceived, CloseAbnormalClosure, 1015:
+		return false
+	}
+	return (code >= 1000 && code <= 1014) || (code >= 3000 && code <= 4999)
+}
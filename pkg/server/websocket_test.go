@@ -0,0 +1,170 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/i9si-sistemas/assert"
+)
+
+func echoWebSocket(conn *WebSocketConn) error {
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if err := conn.WriteMessage(messageType, data); err != nil {
+			return err
+		}
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	server := New(0)
+	assert.NoError(t, server.WebSocket("/echo", WebSocketConfig{ReadLimit: 64}, echoWebSocket))
+
+	t.Run("JSON", func(t *testing.T) {
+		conn, res, err := server.Test().WebSocket("/echo")
+		assert.NoError(t, err)
+		defer conn.Close()
+		assert.Equal(t, res.StatusCode, http.StatusSwitchingProtocols)
+
+		assert.NoError(t, conn.WriteJSON(JSON{"message": "hello"}))
+		var payload JSON
+		assert.NoError(t, conn.ReadJSON(&payload))
+		assert.Equal(t, payload["message"], "hello")
+	})
+
+	t.Run("Fragmentation", func(t *testing.T) {
+		conn, _, err := server.Test().WebSocket("/echo")
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		conn.SetFragmentSize(3)
+		assert.NoError(t, conn.WriteMessage(BinaryMessage, []byte("fragmented")))
+		messageType, data, err := conn.ReadMessage()
+		assert.NoError(t, err)
+		assert.Equal(t, messageType, BinaryMessage)
+		assert.Equal(t, string(data), "fragmented")
+	})
+
+	t.Run("PingPong", func(t *testing.T) {
+		conn, _, err := server.Test().WebSocket("/echo")
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		pong := make(chan string, 1)
+		conn.SetPongHandler(func(data []byte) error {
+			pong <- string(data)
+			return nil
+		})
+		assert.NoError(t, conn.Ping([]byte("ping")))
+		assert.NoError(t, conn.WriteMessage(TextMessage, []byte("after ping")))
+		_, data, err := conn.ReadMessage()
+		assert.NoError(t, err)
+		assert.Equal(t, string(data), "after ping")
+		assert.Equal(t, <-pong, "ping")
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		conn, _, err := server.Test().WebSocket("/echo")
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		assert.NoError(t, conn.WriteClose(CloseGoingAway, "bye"))
+		_, _, err = conn.ReadMessage()
+		assert.True(t, IsCloseError(err, CloseGoingAway))
+		assert.Equal(t, conn.WriteMessage(TextMessage, []byte("late")), ErrCloseSent)
+	})
+
+	t.Run("ReadLimit", func(t *testing.T) {
+		conn, _, err := server.Test().WebSocket("/echo")
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		assert.NoError(t, conn.WriteMessage(TextMessage, []byte(strings.Repeat("a", 65))))
+		_, _, err = conn.ReadMessage()
+		assert.True(t, IsCloseError(err, CloseMessageTooBig))
+	})
+
+	t.Run("ReadLimitFragmented", func(t *testing.T) {
+		conn, _, err := server.Test().WebSocket("/echo")
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		conn.SetFragmentSize(32)
+		message := strings.Repeat("a", 64)
+		assert.NoError(t, conn.WriteMessage(TextMessage, []byte(message)))
+		_, data, err := conn.ReadMessage()
+		assert.NoError(t, err)
+		assert.Equal(t, string(data), message)
+
+		assert.NoError(t, conn.WriteMessage(TextMessage, []byte(message+"a")))
+		_, _, err = conn.ReadMessage()
+		assert.True(t, IsCloseError(err, CloseMessageTooBig))
+	})
+
+	t.Run("HandlerError", func(t *testing.T) {
+		server := New(0)
+		server.WebSocket("/fail", func(conn *WebSocketConn) error {
+			return errors.New("failed")
+		})
+		conn, _, err := server.Test().WebSocket("/fail")
+		assert.NoError(t, err)
+		defer conn.Close()
+		_, _, err = conn.ReadMessage()
+		assert.True(t, IsCloseError(err, CloseInternalServerErr))
+	})
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	server := New(0)
+	api := server.Group("/api", func(c *Context) error {
+		if c.Query("token") != "secret" {
+			return c.SendStatus(http.StatusUnauthorized)
+		}
+		return nil
+	})
+	assert.NoError(t, api.WebSocket("/echo", WebSocketConfig{
+		Origins:      []string{"https://example.com"},
+		Subprotocols: []string{"chat"},
+	}, echoWebSocket))
+
+	t.Run("Middleware", func(t *testing.T) {
+		_, res, err := server.Test().WebSocket("/api/echo")
+		assert.Error(t, err)
+		assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+	})
+
+	t.Run("Origin", func(t *testing.T) {
+		header := http.Header{"Origin": {"https://evil.com"}}
+		_, res, err := server.Test().WebSocket("/api/echo?token=secret", header)
+		assert.Error(t, err)
+		assert.Equal(t, res.StatusCode, http.StatusForbidden)
+	})
+
+	t.Run("Subprotocol", func(t *testing.T) {
+		header := http.Header{
+			"Origin":                 {"https://example.com"},
+			"Sec-Websocket-Protocol": {"json, chat"},
+		}
+		conn, _, err := server.Test().WebSocket("/api/echo?token=secret", header)
+		assert.NoError(t, err)
+		defer conn.Close()
+		assert.Equal(t, conn.Subprotocol(), "chat")
+	})
+
+	t.Run("NotAWebSocket", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/echo?token=secret", nil)
+		res := server.Test().Request(req)
+		assert.Equal(t, res.Code, http.StatusBadRequest)
+	})
+
+	t.Run("InvalidHandler", func(t *testing.T) {
+		assert.Error(t, server.WebSocket("/invalid", func(c *Context) error { return nil }))
+		assert.Equal(t, server.WebSocket("/empty"), ErrPutAHandler)
+	})
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// wrappedWriter is embedded by the writers the middlewares wrap around the
+// response. It forwards flushes and hijacks through http.ResponseController,
+// so streaming and WebSocket handlers still work behind the middlewares and
+// see http.ErrNotSupported, and unwraps to the writer it wraps.
+type wrappedWriter struct {
+	http.ResponseWriter
+	// beforeFlush sends what the middleware holds back.
+	beforeFlush func()
+	// beforeHijack stops the middleware from handling the response.
+	beforeHijack func()
+}
+
+func (w *wrappedWriter) Flush() {
+	w.FlushError()
+}
+
+func (w *wrappedWriter) FlushError() error {
+	if w.beforeFlush != nil {
+		w.beforeFlush()
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *wrappedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if w.beforeHijack != nil {
+		w.beforeHijack()
+	}
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+func (w *wrappedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// responseWriter wraps an http.ResponseWriter to record the status and the
+// size of the response written through it, for middlewares that inspect the
+// response after calling Context.Next.
+type responseWriter struct {
+	wrappedWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	rw := &responseWriter{wrappedWriter: wrappedWriter{ResponseWriter: w}}
+	rw.beforeFlush = rw.writeDefaultHeader
+	return rw
+}
+
+// Status returns the status written, http.StatusOK when nothing was written.
+func (w *responseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Bytes returns the number of body bytes written.
+func (w *responseWriter) Bytes() int64 {
+	return w.bytes
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if !w.wroteHeader && (code >= http.StatusOK || code == http.StatusSwitchingProtocols) {
+		w.wroteHeader = true
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.writeDefaultHeader()
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	w.writeDefaultHeader()
+	var (
+		n   int64
+		err error
+	)
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(struct{ io.Writer }{w.ResponseWriter}, r)
+	}
+	w.bytes += n
+	return n, err
+}
+
+// writeDefaultHeader writes the http.StatusOK header, unless a header was written.
+func (w *responseWriter) writeDefaultHeader() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.wrappedWriter.Hijack()
+	if err == nil && !w.wroteHeader {
+		w.wroteHeader = true
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
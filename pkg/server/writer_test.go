@@ -0,0 +1,22 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/i9si-sistemas/assert"
+)
+
+func TestResponseWriterFlush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	err := http.NewResponseController(newResponseWriter(rec)).Flush()
+	assert.NoError(t, err)
+	assert.True(t, rec.Flushed)
+
+	w := newResponseWriter(struct{ http.ResponseWriter }{httptest.NewRecorder()})
+	err = http.NewResponseController(w).Flush()
+	assert.True(t, errors.Is(err, http.ErrNotSupported))
+	assert.Equal(t, w.Status(), http.StatusOK)
+}
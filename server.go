@@ -8,7 +8,8 @@ import (
 type Server server.Manager
 
 // NewServer returns a new (server.Server) instance bound to the specified port.
-// It accepts both integer and string types for the port.
-func NewServer[T string | int](port T) Server {
-	return server.New(port)
+// It accepts both integer and string types for the port, and optionally
+// the server.ServerOpts used to customize it.
+func NewServer[T string | int](port T, opts ...server.ServerOpts) Server {
+	return server.New(port, opts...)
 }
@@ -3,6 +3,7 @@ package spy
 import (
 	"context"
 	"io/fs"
+	"net"
 	"sync"
 
 	i9 "github.com/i9si-sistemas/nine/pkg/server"
@@ -13,19 +14,40 @@ type Server struct {
 	mu *sync.Mutex
 
 	// Recorded method calls
-	UseCalls          []UseCall
-	GetCalls          []RouteCall
-	PostCalls         []RouteCall
-	PutCalls          []RouteCall
-	PatchCalls        []RouteCall
-	DeleteCalls       []RouteCall
-	RouteCalls        []RouteCall
-	GroupCalls        []GroupCall
-	ServeFilesCalls   []ServeFilesCall
-	TestCalls         int
-	ListenCalls       int
-	ShutdownCalls     []context.Context
-	CertFile, KeyFile string
+	UseCalls           []UseCall
+	GetCalls           []RouteCall
+	PostCalls          []RouteCall
+	PutCalls           []RouteCall
+	PatchCalls         []RouteCall
+	DeleteCalls        []RouteCall
+	WebSocketCalls     []RouteCall
+	RouteCalls         []RouteCall
+	GroupCalls         []GroupCall
+	ServeFilesCalls    []ServeFilesCall
+	MetricsCalls       []MetricsCall
+	AccessLogCalls     [][]i9.AccessLogConfig
+	HealthCalls        [][]i9.HealthConfig
+	TestCalls          int
+	ListenCalls        int
+	ServeCalls         []net.Listener
+	ListenUnixCalls    []ListenUnixCall
+	ListenSystemdCalls int
+	ShutdownCalls      []context.Context
+	RunCalls           []context.Context
+	StartupHooks       []i9.Hook
+	ShutdownHooks      []i9.Hook
+	MarkReadyCalls     int
+	CertFile, KeyFile  string
+}
+
+type ListenUnixCall struct {
+	Path string
+	Mode fs.FileMode
+}
+
+type MetricsCall struct {
+	Endpoint string
+	Options  []i9.MetricsConfig
 }
 
 type UseCall struct {
@@ -61,6 +83,7 @@ func NewServer() *Server {
 		PutCalls:        []RouteCall{},
 		PatchCalls:      []RouteCall{},
 		DeleteCalls:     []RouteCall{},
+		WebSocketCalls:  []RouteCall{},
 		RouteCalls:      []RouteCall{},
 		GroupCalls:      []GroupCall{},
 		ServeFilesCalls: []ServeFilesCall{},
@@ -147,6 +170,19 @@ func (s *Server) Delete(path string, handlers ...any) error {
 	return err
 }
 
+func (s *Server) WebSocket(path string, handlers ...any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := error(nil)
+	s.WebSocketCalls = append(s.WebSocketCalls, RouteCall{
+		Path:     path,
+		Handlers: handlers,
+		Err:      err,
+	})
+	return err
+}
+
 func (s *Server) Route(prefix string, fn func(i9.RouteManager)) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -204,6 +240,36 @@ func (s *Server) ServeFilesWithFS(prefix string, fs fs.FS) {
 	})
 }
 
+// Metrics records the call and returns the configured registry, or a new one.
+func (s *Server) Metrics(endpoint string, options ...i9.MetricsConfig) (*i9.Registry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.MetricsCalls = append(s.MetricsCalls, MetricsCall{
+		Endpoint: endpoint,
+		Options:  options,
+	})
+	if len(options) > 0 && options[0].Registry != nil {
+		return options[0].Registry, nil
+	}
+	return i9.NewRegistry(), nil
+}
+
+func (s *Server) AccessLog(options ...i9.AccessLogConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.AccessLogCalls = append(s.AccessLogCalls, options)
+}
+
+func (s *Server) Health(options ...i9.HealthConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.HealthCalls = append(s.HealthCalls, options)
+	return nil
+}
+
 func (s *Server) Test() *i9.TestServer {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -228,10 +294,48 @@ func (s *Server) ListenTLS(certFile, keyFile string) error {
 	return nil
 }
 
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ServeCalls = append(s.ServeCalls, ln)
+	return nil
+}
+
+func (s *Server) ListenUnix(path string, mode fs.FileMode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ListenUnixCalls = append(s.ListenUnixCalls, ListenUnixCall{
+		Path: path,
+		Mode: mode,
+	})
+	return nil
+}
+
+func (s *Server) ListenSystemd() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ListenSystemdCalls++
+	return nil
+}
+
 func (s *Server) Port() (port string) {
 	return
 }
 
+func (s *Server) Addr() (addr net.Addr) {
+	return
+}
+
+// Ready returns an already closed channel, since the spy never listens.
+func (s *Server) Ready() <-chan struct{} {
+	ready := make(chan struct{})
+	close(ready)
+	return ready
+}
+
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -240,6 +344,42 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+func (s *Server) Run(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.RunCalls = append(s.RunCalls, ctx)
+	return nil
+}
+
+func (s *Server) OnStartup(hooks ...i9.Hook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.StartupHooks = append(s.StartupHooks, hooks...)
+}
+
+func (s *Server) OnShutdown(hooks ...i9.Hook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ShutdownHooks = append(s.ShutdownHooks, hooks...)
+}
+
+func (s *Server) MarkReady() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.MarkReadyCalls++
+}
+
+func (s *Server) IsReady() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.MarkReadyCalls > 0
+}
+
 // RouteGroup is a spy implementation of server.RouteGroup that tracks calls
 type RouteGroup struct {
 	parent *Server
@@ -313,6 +453,19 @@ func (g *RouteGroup) Delete(path string, handlers ...any) error {
 	return err
 }
 
+func (g *RouteGroup) WebSocket(path string, handlers ...any) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	err := error(nil)
+	g.parent.WebSocketCalls = append(g.parent.WebSocketCalls, RouteCall{
+		Path:     g.prefix + path,
+		Handlers: handlers,
+		Err:      err,
+	})
+	return err
+}
+
 func (g *RouteGroup) Use(middlewares ...any) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
@@ -2,6 +2,8 @@ package spy
 
 import (
 	"context"
+	"io/fs"
+	"net"
 	"os"
 	"testing"
 
@@ -27,6 +29,7 @@ func TestServerSpy(t *testing.T) {
 		assert.Equal(t, len(s.PutCalls), 0)
 		assert.Equal(t, len(s.PatchCalls), 0)
 		assert.Equal(t, len(s.DeleteCalls), 0)
+		assert.Equal(t, len(s.WebSocketCalls), 0)
 		assert.Equal(t, len(s.RouteCalls), 0)
 		assert.Equal(t, len(s.GroupCalls), 0)
 		assert.Equal(t, len(s.ServeFilesCalls), 0)
@@ -61,6 +64,7 @@ func TestServerSpy(t *testing.T) {
 			{s.Put, &s.PutCalls, "/put", []any{handler}},
 			{s.Patch, &s.PatchCalls, "/patch", []any{handler}},
 			{s.Delete, &s.DeleteCalls, "/delete", []any{handler}},
+			{s.WebSocket, &s.WebSocketCalls, "/ws", []any{handler}},
 		}
 
 		for _, tt := range tests {
@@ -132,6 +136,69 @@ func TestServerSpy(t *testing.T) {
 		assert.Equal(t, 1, s.ListenCalls)
 	})
 
+	t.Run("Serve and ListenUnix record calls", func(t *testing.T) {
+		s := NewServer()
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		defer ln.Close()
+
+		assert.NoError(t, s.Serve(ln))
+		assert.NoError(t, s.ListenUnix("/tmp/app.sock", 0o660))
+		assert.NoError(t, s.ListenSystemd())
+		assert.Equal(t, len(s.ServeCalls), 1)
+		assert.Equal(t, s.ListenUnixCalls[0].Path, "/tmp/app.sock")
+		assert.Equal(t, s.ListenUnixCalls[0].Mode, fs.FileMode(0o660))
+		assert.Equal(t, s.ListenSystemdCalls, 1)
+		assert.Nil(t, s.Addr())
+		<-s.Ready()
+	})
+
+	t.Run("Run and hooks record calls", func(t *testing.T) {
+		s := NewServer()
+		hook := func(context.Context) error { return nil }
+		s.OnStartup(hook)
+		s.OnShutdown(hook, hook)
+
+		assert.NoError(t, s.Run(context.Background()))
+		assert.Equal(t, len(s.RunCalls), 1)
+		assert.Equal(t, len(s.StartupHooks), 1)
+		assert.Equal(t, len(s.ShutdownHooks), 2)
+		assert.False(t, s.IsReady())
+		s.MarkReady()
+		assert.True(t, s.IsReady())
+	})
+
+	t.Run("Metrics records calls", func(t *testing.T) {
+		s := NewServer()
+		registry := i9.NewRegistry()
+		got, err := s.Metrics("/metrics", i9.MetricsConfig{Registry: registry})
+
+		assert.NoError(t, err)
+		assert.True(t, got == registry)
+		assert.Equal(t, s.MetricsCalls[0].Endpoint, "/metrics")
+		got, err = s.Metrics("/other")
+		assert.NoError(t, err)
+		assert.NotNil(t, got)
+		assert.Equal(t, len(s.MetricsCalls), 2)
+	})
+
+	t.Run("AccessLog records calls", func(t *testing.T) {
+		s := NewServer()
+		s.AccessLog(i9.AccessLogConfig{Format: i9.AccessLogJSON})
+
+		assert.Equal(t, len(s.AccessLogCalls), 1)
+		assert.Equal(t, s.AccessLogCalls[0][0].Format, i9.AccessLogJSON)
+	})
+
+	t.Run("Health records calls", func(t *testing.T) {
+		s := NewServer()
+		config := i9.HealthConfig{LivenessPath: "/healthz"}
+
+		assert.NoError(t, s.Health(config))
+		assert.Equal(t, len(s.HealthCalls), 1)
+		assert.Equal(t, s.HealthCalls[0][0].LivenessPath, "/healthz")
+	})
+
 	t.Run("Shutdown records context", func(t *testing.T) {
 		s := NewServer()
 		ctx := context.Background()
@@ -160,6 +227,7 @@ func TestRouteGroupSpy(t *testing.T) {
 			{group.Put, &s.PutCalls, "/users/1", []any{handler}},
 			{group.Patch, &s.PatchCalls, "/users/1", []any{handler}},
 			{group.Delete, &s.DeleteCalls, "/users/1", []any{handler}},
+			{group.WebSocket, &s.WebSocketCalls, "/users/ws", []any{handler}},
 		}
 
 		for _, tt := range tests {